@@ -0,0 +1,99 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// benchWriter discards the response without allocating per request.
+type benchWriter struct {
+	header http.Header
+}
+
+func (w *benchWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *benchWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *benchWriter) WriteHeader(status int) {}
+
+type benchIn struct {
+	ID   int64  `path:"id"`
+	Name string `json:"name" valid:"required"`
+}
+
+type benchOut struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+}
+
+func benchRouter() *Router {
+	router := New()
+	router.GET("/ping", func(c *Context) {
+		c.Json("pong")
+	})
+	router.GET("/error/:id", func(c *Context) error {
+		if c.Param("id") == "" {
+			return &ErrorResponse{Status: http.StatusNotFound}
+		}
+		c.Json("ok")
+		return nil
+	})
+	router.POST("/users/:id", func(in *benchIn, out *benchOut) {
+		out.ID, out.Name = in.ID, in.Name
+	})
+	router.POST("/typed/:id", Handle(func(c *Context, in *benchIn) (*benchOut, error) {
+		return &benchOut{ID: in.ID, Name: in.Name}, nil
+	}))
+	return router
+}
+
+func benchmarkRequest(b *testing.B, method, path, body string) {
+	router := benchRouter()
+	w := &benchWriter{header: http.Header{}}
+	req := httptest.NewRequest(method, path, nil)
+	reader := strings.NewReader(body)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(body)
+		req.Body = readCloser{reader}
+		for key := range w.header {
+			delete(w.header, key)
+		}
+		router.ServeHTTP(w, req)
+	}
+}
+
+type readCloser struct {
+	*strings.Reader
+}
+
+func (readCloser) Close() error {
+	return nil
+}
+
+func BenchmarkContextHandler(b *testing.B) {
+	benchmarkRequest(b, http.MethodGet, "/ping", "")
+}
+
+func BenchmarkErrorHandler(b *testing.B) {
+	benchmarkRequest(b, http.MethodGet, "/error/1", "")
+}
+
+func BenchmarkInOutHandler(b *testing.B) {
+	benchmarkRequest(b, http.MethodPost, "/users/1", `{"name":"Lywane"}`)
+}
+
+func BenchmarkTypedHandler(b *testing.B) {
+	benchmarkRequest(b, http.MethodPost, "/typed/1", `{"name":"Lywane"}`)
+}
+
+func BenchmarkNotFound(b *testing.B) {
+	benchmarkRequest(b, http.MethodGet, "/missing", "")
+}
@@ -0,0 +1,450 @@
+package http
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultMaxMemory = 32 << 20
+
+// binding sources, in the order they are applied
+const (
+	sourceForm = iota
+	sourceQuery
+	sourceHeader
+	sourcePath
+	sourceCount
+)
+
+var sourceTags = [sourceCount]string{"form", "query", "header", "path"}
+
+type bindField struct {
+	index  []int
+	name   string
+	source int
+	layout string
+	// file is set for *multipart.FileHeader and []*multipart.FileHeader
+	// form fields
+	file bool
+}
+
+type bindPlan struct {
+	fields  []bindField
+	sources [sourceCount]bool
+}
+
+var bindPlans sync.Map
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+var errUnsupportedMediaType = errors.New("http: unsupported content type")
+
+// BindError reports where binding the request failed. Source is body, form,
+// query, header or path.
+type BindError struct {
+	Source string `json:"source"`
+	Name   string `json:"field,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Offset int64  `json:"offset,omitempty"`
+	Err    error  `json:"-"`
+}
+
+func (e *BindError) Error() string {
+	if e.Source == "body" {
+		switch {
+		case e.Name != "":
+			return fmt.Sprintf("body field '%s': %v", e.Name, e.Err)
+		case e.Offset > 0:
+			return fmt.Sprintf("body at offset %d: %v", e.Offset, e.Err)
+		}
+		return fmt.Sprintf("body: %v", e.Err)
+	}
+	if e.Source == "file" {
+		return fmt.Sprintf("file '%s' of '%s': %v", e.Value, e.Name, e.Err)
+	}
+	return fmt.Sprintf("%s param '%s': cannot convert '%s': %v", e.Source, e.Name, e.Value, e.Err)
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+func isBindError(err error) bool {
+	var maxBytes *http.MaxBytesError
+	var bindErr *BindError
+	return errors.As(err, &maxBytes) || errors.As(err, &bindErr) || errors.Is(err, errUnsupportedMediaType) ||
+		errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart)
+}
+
+// bindFailed answers 413 for bodies and files over the limit, 415 for
+// unsupported content and file types and 400 for everything else.
+func (this *Context) bindFailed(err error) {
+	var maxBytes *http.MaxBytesError
+	var bindErr *BindError
+	errors.As(err, &bindErr)
+	switch {
+	case errors.As(err, &maxBytes):
+		this.writeError(http.StatusRequestEntityTooLarge, &ErrorResponse{
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("request body is larger than %d bytes", maxBytes.Limit),
+		})
+	case errors.Is(err, errUnsupportedMediaType):
+		this.writeError(http.StatusUnsupportedMediaType, &ErrorResponse{
+			Status:  http.StatusUnsupportedMediaType,
+			Message: err.Error(),
+		})
+	case errors.Is(err, ErrFileTooLarge):
+		this.writeError(http.StatusRequestEntityTooLarge, &ErrorResponse{
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: err.Error(),
+			Data:    bindErr,
+		})
+	case errors.Is(err, ErrFileType):
+		this.writeError(http.StatusUnsupportedMediaType, &ErrorResponse{
+			Status:  http.StatusUnsupportedMediaType,
+			Message: err.Error(),
+			Data:    bindErr,
+		})
+	case bindErr != nil:
+		this.writeError(http.StatusBadRequest, &ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+			Data:    bindErr,
+		})
+	default:
+		this.writeError(http.StatusBadRequest, &ErrorResponse{
+			Status:  http.StatusBadRequest,
+			Message: err.Error(),
+		})
+	}
+}
+
+// Bind fills dst, a pointer to struct, from the request. The body is decoded
+// by Content-Type into the json (or form) fields, then fields tagged with
+// form, query, header and path are set from those sources:
+//
+//	type In struct {
+//		ID    int64     `path:"id"`
+//		Page  int       `query:"page"`
+//		Tags  []string  `query:"tag"`
+//		Token string    `header:"X-Token"`
+//		Since time.Time `query:"since" time_format:"2006-01-02"`
+//		Name  string    `json:"name"`
+//	}
+//
+// Multipart files bind to *multipart.FileHeader and []*multipart.FileHeader
+// fields tagged with form.
+func (this *Context) Bind(dst interface{}) error {
+	value := reflect.ValueOf(dst)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("http: Bind needs a pointer to struct, got %T", dst)
+	}
+	plan := planOf(value.Type().Elem())
+
+	form, err := this.bindBody(dst, plan)
+	if err != nil {
+		return err
+	}
+
+	elem := value.Elem()
+	var query url.Values
+	if plan.sources[sourceQuery] {
+		query = this.Request.URL.Query()
+	}
+	for _, field := range plan.fields {
+		if field.file {
+			if this.Request.MultipartForm != nil {
+				setFiles(fieldByIndex(elem, field.index), this.Request.MultipartForm.File[field.name])
+			}
+			continue
+		}
+		var values []string
+		switch field.source {
+		case sourceForm:
+			values = form[field.name]
+		case sourceQuery:
+			values = query[field.name]
+		case sourceHeader:
+			values = this.Request.Header.Values(field.name)
+		case sourcePath:
+			if value, exist := this.paramValue(field.name); exist {
+				values = []string{value}
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if err := setField(fieldByIndex(elem, field.index), values, field.layout); err != nil {
+			return &BindError{Source: sourceTags[field.source], Name: field.name, Value: strings.Join(values, ","), Err: err}
+		}
+	}
+	return nil
+}
+
+func setFiles(v reflect.Value, files []*multipart.FileHeader) {
+	if len(files) == 0 {
+		return
+	}
+	if v.Type() == fileHeaderType {
+		v.Set(reflect.ValueOf(files[0]))
+		return
+	}
+	v.Set(reflect.ValueOf(files))
+}
+
+func (this *Context) paramValue(name string) (string, bool) {
+	for _, param := range this.params {
+		if param.Key == name {
+			return param.Value, true
+		}
+	}
+	return "", false
+}
+
+// bindBody decodes the body into dst and returns the form values of form
+// encoded bodies.
+func (this *Context) bindBody(dst interface{}, plan *bindPlan) (url.Values, error) {
+	contentType, _, _ := mime.ParseMediaType(this.Request.Header.Get("Content-Type"))
+	if contentType == "multipart/form-data" {
+		if !plan.sources[sourceForm] {
+			return nil, nil
+		}
+		form, err := this.MultipartForm()
+		if err != nil {
+			return nil, err
+		}
+		return url.Values(form.Value), nil
+	}
+	body, err := this.ReadBody()
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, nil
+	}
+	switch contentType {
+	case "application/x-www-form-urlencoded":
+		if !plan.sources[sourceForm] {
+			return nil, nil
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, &BindError{Source: "body", Err: err}
+		}
+		return form, nil
+	case "", "application/json", "text/json":
+		return nil, decodeJSON(body, dst, this.disallowUnknownFields())
+	}
+	if strings.HasSuffix(contentType, "+json") {
+		return nil, decodeJSON(body, dst, this.disallowUnknownFields())
+	}
+	if entry := codecOf(contentType); entry != nil {
+		if err := entry.codec.Unmarshal(body, dst); err != nil {
+			return nil, &BindError{Source: "body", Err: err}
+		}
+		return nil, nil
+	}
+	return nil, fmt.Errorf("%w %s", errUnsupportedMediaType, contentType)
+}
+
+// decodeJSON decodes a single JSON value, errors tell where it failed.
+func decodeJSON(body []byte, dst interface{}, disallowUnknownFields bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	if disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	err := decoder.Decode(dst)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the JSON value")
+		return &BindError{Source: "body", Offset: decoder.InputOffset(), Err: err}
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case err == nil, err == io.EOF:
+		return nil
+	case errors.As(err, &syntaxErr):
+		return &BindError{Source: "body", Offset: syntaxErr.Offset, Err: err}
+	case errors.As(err, &typeErr):
+		err = fmt.Errorf("cannot unmarshal %s into %s", typeErr.Value, typeErr.Type)
+		return &BindError{Source: "body", Name: typeErr.Field, Offset: typeErr.Offset, Err: err}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &BindError{Source: "body", Offset: int64(len(body)), Err: err}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		return &BindError{Source: "body", Name: name, Err: errors.New("unknown field")}
+	}
+	return &BindError{Source: "body", Err: err}
+}
+
+func planOf(t reflect.Type) *bindPlan {
+	if plan, ok := bindPlans.Load(t); ok {
+		return plan.(*bindPlan)
+	}
+	plan := &bindPlan{}
+	collectFields(plan, t, nil)
+	actual, _ := bindPlans.LoadOrStore(t, plan)
+	return actual.(*bindPlan)
+}
+
+func collectFields(plan *bindPlan, t reflect.Type, index []int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		tagged := false
+		for source, tag := range sourceTags {
+			name, exist := field.Tag.Lookup(tag)
+			if !exist || name == "-" || field.PkgPath != "" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			tagged = true
+			plan.sources[source] = true
+			plan.fields = append(plan.fields, bindField{
+				index:  fieldIndex,
+				name:   name,
+				source: source,
+				layout: field.Tag.Get("time_format"),
+				file:   source == sourceForm && (field.Type == fileHeaderType || field.Type == fileHeaderSliceType),
+			})
+		}
+		if tagged || !field.Anonymous {
+			continue
+		}
+		embedded := field.Type
+		if embedded.Kind() == reflect.Ptr {
+			embedded = embedded.Elem()
+		}
+		if embedded.Kind() == reflect.Struct {
+			collectFields(plan, embedded, fieldIndex)
+		}
+	}
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex allocating nil embedded pointers.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func setField(v reflect.Value, values []string, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setField(v.Elem(), values, layout)
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 && !v.Addr().Type().Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value, layout); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	return setValue(v, values[0], layout)
+}
+
+func setValue(v reflect.Value, value string, layout string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch {
+	case v.Type() == timeType:
+		t, err := parseTime(value, layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.Addr().Type().Implements(textUnmarshalerType):
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		// []byte
+		v.SetBytes([]byte(value))
+	default:
+		return errors.New("unsupported field type " + v.Type().String())
+	}
+	return nil
+}
+
+// parseTime parses value with layout, or with DefaultDateTimeFM,
+// DefaultDateFM and RFC 3339 when no layout is given.
+func parseTime(value, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.ParseInLocation(layout, value, time.Local)
+	}
+	for _, layout := range []string{DefaultDateTimeFM, DefaultDateFM} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type bindIn struct {
+	ID       int64         `path:"id"`
+	Page     int           `query:"page"`
+	Tags     []string      `query:"tag"`
+	Active   *bool         `query:"active"`
+	Since    time.Time     `query:"since"`
+	Day      time.Time     `query:"day" time_format:"20060102"`
+	Timeout  time.Duration `query:"timeout"`
+	Token    string        `header:"X-Token"`
+	Name     string        `json:"name"`
+	Birthday string        `json:"birthday"`
+}
+
+func TestContext_Bind(t *testing.T) {
+	var got bindIn
+	router := New()
+	router.POST("/users/:id", func(c *Context) {
+		if err := c.Bind(&got); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodPost,
+		"/users/42?page=3&tag=a&tag=b&active=true&since=1994-06-25&day=19940625&timeout=1m30s",
+		strings.NewReader(`{"name":"Lywane","birthday":"1994-06-25"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Token", "secret")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	since := time.Date(1994, 6, 25, 0, 0, 0, 0, time.Local)
+	if got.ID != 42 || got.Page != 3 || strings.Join(got.Tags, ",") != "a,b" ||
+		got.Active == nil || !*got.Active || !got.Since.Equal(since) || !got.Day.Equal(since) ||
+		got.Timeout != 90*time.Second || got.Token != "secret" ||
+		got.Name != "Lywane" || got.Birthday != "1994-06-25" {
+		t.Errorf("unexpected binding %+v", got)
+	}
+}
+
+func TestContext_BindForm(t *testing.T) {
+	var got struct {
+		Name string `form:"name"`
+		Age  uint8  `form:"age"`
+	}
+	router := New()
+	router.POST("/form", func(c *Context) {
+		if err := c.Bind(&got); err != nil {
+			t.Fatal(err)
+		}
+	})
+	req := httptest.NewRequest(http.MethodPost, "/form", strings.NewReader("name=Lywane&age=24"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	if got.Name != "Lywane" || got.Age != 24 {
+		t.Errorf("unexpected binding %+v", got)
+	}
+}
+
+func TestContext_BindError(t *testing.T) {
+	var err error
+	router := New()
+	router.GET("/users/:id", func(c *Context) {
+		err = c.Bind(&bindIn{})
+	})
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/abc", nil))
+	bindErr, ok := err.(*BindError)
+	if !ok || bindErr.Source != "path" || bindErr.Name != "id" || bindErr.Value != "abc" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestContext_BindStrict(t *testing.T) {
+	router := New()
+	router.SetMaxBodySize(64)
+	router.POST("/hello", HelloPost)
+	router.POST("/strict", HelloPost).DisallowUnknownFields()
+	router.POST("/small", HelloPost).MaxBodySize(8)
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+		error  string
+	}{
+		{"/hello", `{"name":"Lywane"}`, http.StatusOK, ""},
+		{"/hello", `{"name":"Lywane",}`, http.StatusBadRequest, `{"status":400,"message":"body at offset 18: invalid character '}' looking for beginning of object key string","data":{"source":"body","offset":18}}`},
+		{"/hello", `{"name":1}`, http.StatusBadRequest, `{"status":400,"message":"body field 'name': cannot unmarshal number into string","data":{"source":"body","field":"name","offset":9}}`},
+		{"/hello", `{"name":"Lywane"`, http.StatusBadRequest, `{"status":400,"message":"body at offset 16: unexpected EOF","data":{"source":"body","offset":16}}`},
+		{"/hello", `{"name":"Lywane","age":1}`, http.StatusOK, ""},
+		{"/strict", `{"name":"Lywane","age":1}`, http.StatusBadRequest, `{"status":400,"message":"body field 'age': unknown field","data":{"source":"body","field":"age"}}`},
+		{"/hello", `{"name":"` + strings.Repeat("a", 64) + `"}`, http.StatusRequestEntityTooLarge, `{"status":413,"message":"request body is larger than 64 bytes"}`},
+		{"/small", `{"name":"Lywane"}`, http.StatusRequestEntityTooLarge, `{"status":413,"message":"request body is larger than 8 bytes"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d %s", test.path, test.body, test.status, w.Code, w.Body.String())
+		}
+		if test.error != "" && w.Body.String() != test.error {
+			t.Errorf("%s %s: unexpected body %s", test.path, test.body, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader("name,Lywane"))
+	req.Header.Set("Content-Type", "text/csv")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected 415, got %d", w.Code)
+	}
+}
@@ -0,0 +1,291 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes response bodies and decodes request bodies of one MIME type.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+type codecEntry struct {
+	mimeType    string
+	contentType string
+	codec       Codec
+}
+
+var (
+	codecsMu     sync.RWMutex
+	codecs       = make(map[string]*codecEntry)
+	codecFormats = make(map[string]*codecEntry)
+	// codecOrder keeps registration order for Accept wildcards like text/*
+	codecOrder []*codecEntry
+)
+
+func init() {
+	RegisterCodec("application/json", JSONCodec{}, "json")
+	RegisterCodec("text/json", JSONCodec{})
+	RegisterCodec("application/xml", XMLCodec{}, "xml")
+	RegisterCodec("text/xml", XMLCodec{})
+	RegisterCodec("text/plain", TextCodec{}, "text", "txt")
+}
+
+// maxDecodeDepth limits the nesting of YAML and MessagePack bodies like
+// encoding/json limits JSON, so deep bodies fail instead of exhausting the
+// stack.
+const maxDecodeDepth = 10000
+
+var errDecodeDepth = fmt.Errorf("exceeded max depth of %d", maxDecodeDepth)
+
+// RegisterCodec registers codec for mimeType, formats are the names usable
+// in ?format= to pick it. Register codecs before serving.
+func RegisterCodec(mimeType string, codec Codec, formats ...string) {
+	entry := &codecEntry{
+		mimeType:    mimeType,
+		contentType: mimeType,
+		codec:       codec,
+	}
+	if strings.HasPrefix(mimeType, "text/") || mimeType == "application/json" ||
+		mimeType == "application/xml" || mimeType == "application/yaml" {
+		entry.contentType += ";charset=UTF-8"
+	}
+
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	if old, exist := codecs[mimeType]; exist {
+		for i := range codecOrder {
+			if codecOrder[i] == old {
+				codecOrder[i] = entry
+			}
+		}
+	} else {
+		codecOrder = append(codecOrder, entry)
+	}
+	codecs[mimeType] = entry
+	for _, format := range formats {
+		codecFormats[format] = entry
+	}
+}
+
+func codecOf(mimeType string) *codecEntry {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return codecs[mimeType]
+}
+
+func codecOfFormat(format string) *codecEntry {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	return codecFormats[format]
+}
+
+// negotiate picks the codec from ?format=, then from the Accept header. It
+// falls back to JSON, also when Accept has */* or application/*, so only
+// clients asking for another type explicitly get it.
+func (this *Context) negotiate() *codecEntry {
+	if strings.Contains(this.Request.URL.RawQuery, "format=") {
+		if entry := codecOfFormat(this.Request.URL.Query().Get("format")); entry != nil {
+			return entry
+		}
+	}
+	accept := this.Request.Header.Get("Accept")
+	if accept == "" || accept == "*/*" || strings.HasPrefix(accept, "application/json") {
+		return codecOf("application/json")
+	}
+	accepted := parseAccept(accept)
+	// clients taking anything, like browsers, keep getting JSON
+	for _, mimeType := range accepted {
+		if mimeType == "*/*" || mimeType == "application/*" {
+			return codecOf("application/json")
+		}
+	}
+	for _, mimeType := range accepted {
+		if strings.HasSuffix(mimeType, "/*") {
+			prefix := mimeType[:len(mimeType)-1]
+			codecsMu.RLock()
+			for _, entry := range codecOrder {
+				if strings.HasPrefix(entry.mimeType, prefix) {
+					codecsMu.RUnlock()
+					return entry
+				}
+			}
+			codecsMu.RUnlock()
+			continue
+		}
+		if entry := codecOf(mimeType); entry != nil {
+			return entry
+		}
+	}
+	return codecOf("application/json")
+}
+
+// parseAccept returns the media types of an Accept header by preference,
+// dropping those with q=0.
+func parseAccept(accept string) []string {
+	type accepted struct {
+		mimeType string
+		q        float64
+	}
+	var list []accepted
+	for _, part := range strings.Split(accept, ",") {
+		mimeType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, exist := params["q"]; exist {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			list = append(list, accepted{mimeType, q})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].q > list[j].q
+	})
+	types := make([]string, len(list))
+	for i := range list {
+		types[i] = list[i].mimeType
+	}
+	return types
+}
+
+// Render answers data wrapped in the envelope with the given http status,
+// encoded by the codec the client asks for with ?format= or Accept.
+func (this *Context) Render(status int, data interface{}) {
+	this.render(status, this.renderer().Success(this, data))
+}
+
+func (this *Context) render(status int, body interface{}) {
+	entry := this.negotiate()
+	if _, ok := entry.codec.(JSONCodec); ok {
+		this.JsonRaw(body)
+		this.httpStatus = status
+		this.contentType = entry.contentType
+		return
+	}
+	res, err := entry.codec.Marshal(body)
+	if err != nil {
+		// e.g. maps have no XML form, answer JSON rather than failing
+		Error("[render]", entry.mimeType, err)
+		this.JsonRaw(body)
+		this.httpStatus = status
+		return
+	}
+	this.responseData = res
+	this.httpStatus = status
+	this.hasResponse = true
+	this.contentType = entry.contentType
+}
+
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type XMLCodec struct{}
+
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+// YAMLCodec handles block and flow YAML through the json tags of the value.
+// It covers the subset of YAML used by config-like bodies, without anchors,
+// tags or multi documents, and is only used after RegisterYAMLCodec.
+type YAMLCodec struct{}
+
+// RegisterYAMLCodec registers YAMLCodec for application/yaml,
+// application/x-yaml and text/yaml, picked by ?format=yaml or yml.
+func RegisterYAMLCodec() {
+	RegisterCodec("application/yaml", YAMLCodec{}, "yaml", "yml")
+	RegisterCodec("application/x-yaml", YAMLCodec{})
+	RegisterCodec("text/yaml", YAMLCodec{})
+}
+
+func (YAMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return marshalYAML(v)
+}
+
+func (YAMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalYAML(data, v)
+}
+
+// MsgPackCodec handles MessagePack through the json tags of the value. It is
+// only used after RegisterMsgPackCodec.
+type MsgPackCodec struct{}
+
+// RegisterMsgPackCodec registers MsgPackCodec for application/msgpack,
+// application/x-msgpack and application/vnd.msgpack, picked by
+// ?format=msgpack.
+func RegisterMsgPackCodec() {
+	RegisterCodec("application/msgpack", MsgPackCodec{}, "msgpack")
+	RegisterCodec("application/x-msgpack", MsgPackCodec{})
+	RegisterCodec("application/vnd.msgpack", MsgPackCodec{})
+}
+
+func (MsgPackCodec) Marshal(v interface{}) ([]byte, error) {
+	return marshalMsgPack(v)
+}
+
+func (MsgPackCodec) Unmarshal(data []byte, v interface{}) error {
+	return unmarshalMsgPack(data, v)
+}
+
+// TextCodec writes the data of the envelope, or the message of an error, as
+// plain text. It decodes into *string and *[]byte only.
+type TextCodec struct{}
+
+func (TextCodec) Marshal(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case *envelope:
+		v = value.Data
+	case *ErrorResponse:
+		v = value.Message
+	}
+	switch value := v.(type) {
+	case nil:
+		return []byte{}, nil
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	}
+	return []byte(fmt.Sprint(v)), nil
+}
+
+func (TextCodec) Unmarshal(data []byte, v interface{}) error {
+	switch value := v.(type) {
+	case *string:
+		*value = string(data)
+	case *[]byte:
+		*value = append((*value)[:0], data...)
+	default:
+		return errors.New("text/plain decodes into *string or *[]byte only")
+	}
+	return nil
+}
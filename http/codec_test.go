@@ -0,0 +1,295 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func init() {
+	RegisterYAMLCodec()
+	RegisterMsgPackCodec()
+}
+
+type codecIn struct {
+	Name string   `json:"name" xml:"name" valid:"required"`
+	Tags []string `json:"tags" xml:"tag"`
+}
+
+type codecOut struct {
+	Text string   `json:"text" xml:"text"`
+	Tags []string `json:"tags" xml:"tag"`
+}
+
+func codecRouter() *Router {
+	router := New()
+	router.POST("/hello", func(in *codecIn, out *codecOut) {
+		out.Text = "Hello " + in.Name
+		out.Tags = in.Tags
+	})
+	router.GET("/text", func(c *Context) {
+		c.Render(http.StatusCreated, "plain hello")
+	})
+	router.GET("/labels", func(in *struct{}, out *struct {
+		Labels map[string]string `json:"labels"`
+	}) {
+		out.Labels = map[string]string{"a": "b"}
+	})
+	return router
+}
+
+func TestContext_Render(t *testing.T) {
+	router := codecRouter()
+	tests := []struct {
+		url         string
+		accept      string
+		contentType string
+		status      int
+		body        string
+	}{
+		{"/text", "", "application/json;charset=UTF-8", http.StatusCreated, `{"data":"plain hello","status":0}`},
+		{"/text", "text/plain", "text/plain;charset=UTF-8", http.StatusCreated, `plain hello`},
+		{"/text", "application/*", "application/json;charset=UTF-8", http.StatusCreated, `{"data":"plain hello","status":0}`},
+		{"/text", "application/xml;q=0.5, application/yaml", "application/yaml;charset=UTF-8", http.StatusCreated, "data: \"plain hello\"\nstatus: 0\n"},
+		{"/text", "image/png", "application/json;charset=UTF-8", http.StatusCreated, `{"data":"plain hello","status":0}`},
+		{"/text?format=xml", "text/plain", "application/xml;charset=UTF-8", http.StatusCreated,
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<response><data>plain hello</data><status>0</status></response>"},
+		{"/missing", "application/xml", "application/xml;charset=UTF-8", http.StatusNotFound,
+			"<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<error><status>404</status><message>Not Found</message></error>"},
+		{"/missing", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "application/json;charset=UTF-8", http.StatusNotFound,
+			`{"status":404,"message":"Not Found"}`},
+		{"/text", "application/xml, application/*;q=0.1", "application/json;charset=UTF-8", http.StatusCreated, `{"data":"plain hello","status":0}`},
+		{"/labels", "application/xml", "application/json;charset=UTF-8", http.StatusOK, `{"data":{"labels":{"a":"b"}},"status":0}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, test.url, nil)
+		if test.accept != "" {
+			req.Header.Set("Accept", test.accept)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d", test.url, test.accept, test.status, w.Code)
+		}
+		if got := w.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("%s %s: expected content type %s, got %s", test.url, test.accept, test.contentType, got)
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s %s: unexpected body %q", test.url, test.accept, w.Body.String())
+		}
+	}
+}
+
+func TestContext_BindCodecs(t *testing.T) {
+	router := codecRouter()
+	msgpack, err := marshalMsgPack(&codecIn{Name: "Lywane", Tags: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/json", `{"name":"Lywane","tags":["a","b"]}`},
+		{"application/xml", `<codecIn><name>Lywane</name><tag>a</tag><tag>b</tag></codecIn>`},
+		{"application/yaml", "# user\nname: Lywane\ntags:\n  - a\n  - b\n"},
+		{"application/x-yaml", "name: 'Lywane'\ntags: [a, b]\n"},
+		{"application/msgpack", string(msgpack)},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		req.Header.Set("Accept", test.contentType)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected 200, got %d %s", test.contentType, w.Code, w.Body.String())
+			continue
+		}
+
+		var res struct {
+			Data codecOut `json:"data" xml:"data"`
+		}
+		if err := codecOf(test.contentType).codec.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Errorf("%s: cannot decode response: %v", test.contentType, err)
+			continue
+		}
+		expected := codecOut{Text: "Hello Lywane", Tags: []string{"a", "b"}}
+		if !reflect.DeepEqual(res.Data, expected) {
+			t.Errorf("%s: unexpected response %+v", test.contentType, res.Data)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader("name: [a"))
+	req.Header.Set("Content-Type", "application/yaml")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for broken yaml, got %d", w.Code)
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v interface{}) ([]byte, error) {
+	data, err := TextCodec{}.Marshal(v)
+	return bytes.ToUpper(data), err
+}
+
+func (upperCodec) Unmarshal(data []byte, v interface{}) error {
+	return TextCodec{}.Unmarshal(bytes.ToLower(data), v)
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("text/x-upper", upperCodec{}, "upper")
+	router := codecRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/text?format=upper", nil))
+	if w.Body.String() != "PLAIN HELLO" || w.Header().Get("Content-Type") != "text/x-upper;charset=UTF-8" {
+		t.Errorf("unexpected response %s %s", w.Header().Get("Content-Type"), w.Body.String())
+	}
+}
+
+func TestUnmarshalYAML(t *testing.T) {
+	doc := `---
+name: "Ly\"wane" # comment
+age: 18
+admin: true
+nothing: ~
+note: |
+  line 1
+  line 2
+folded: >-
+  a
+  b
+addresses:
+- city: Hangzhou
+  zip: '310000'
+- {city: Beijing, zip: "100000"}
+matrix:
+  - [1, 2]
+  - []
+`
+	var v map[string]interface{}
+	if err := unmarshalYAML([]byte(doc), &v); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":    `Ly"wane`,
+		"age":     float64(18),
+		"admin":   true,
+		"nothing": nil,
+		"note":    "line 1\nline 2\n",
+		"folded":  "a b",
+		"addresses": []interface{}{
+			map[string]interface{}{"city": "Hangzhou", "zip": "310000"},
+			map[string]interface{}{"city": "Beijing", "zip": "100000"},
+		},
+		"matrix": []interface{}{[]interface{}{float64(1), float64(2)}, []interface{}{}},
+	}
+	if !reflect.DeepEqual(v, expected) {
+		t.Errorf("unexpected %#v", v)
+	}
+
+	// what marshalYAML writes reads back the same
+	doc2, err := marshalYAML(expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v2 map[string]interface{}
+	if err := unmarshalYAML(doc2, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v2, expected) {
+		t.Errorf("round trip of\n%s\ngot %#v", doc2, v2)
+	}
+}
+
+func TestMsgPack(t *testing.T) {
+	in := map[string]interface{}{
+		"small": float64(1), "negative": float64(-100), "big": float64(1 << 40),
+		"pi": 3.14, "text": strings.Repeat("x", 40), "list": []interface{}{true, false, nil},
+	}
+	data, err := marshalMsgPack(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := unmarshalMsgPack(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("unexpected %#v", out)
+	}
+	if err := unmarshalMsgPack(data[:len(data)-1], &out); err == nil {
+		t.Error("expected error for truncated data")
+	}
+}
+
+type msgPackBase struct {
+	ID int64 `json:"id"`
+}
+
+type msgPackIn struct {
+	msgPackBase
+	Avatar  []byte          `json:"avatar"`
+	Raw     json.RawMessage `json:"raw"`
+	At      time.Time       `json:"at"`
+	Note    string          `json:"note,omitempty"`
+	Skipped string          `json:"-"`
+}
+
+func TestMsgPack_Bin(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	in := msgPackIn{msgPackBase: msgPackBase{ID: 7}, Avatar: []byte{0, 1, 0xff}, Raw: json.RawMessage(`{}`), At: at, Skipped: "x"}
+	data, err := marshalMsgPack(&in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte{0xa6, 'a', 'v', 'a', 't', 'a', 'r', 0xc4, 3, 0, 1, 0xff}) ||
+		!bytes.Contains(data, []byte{0xa3, 'r', 'a', 'w', 0xc4, 2, '{', '}'}) {
+		t.Errorf("expected []byte and json.RawMessage as bin in %x", data)
+	}
+
+	var fields map[string]interface{}
+	if err := unmarshalMsgPack(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 4 || fields["id"] != float64(7) || fields["at"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	var out msgPackIn
+	if err := unmarshalMsgPack(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != 7 || !bytes.Equal(out.Avatar, in.Avatar) || !out.At.Equal(at) {
+		t.Errorf("unexpected round trip %+v", out)
+	}
+}
+
+func TestContext_BindDeepBodies(t *testing.T) {
+	router := codecRouter()
+	tests := []struct {
+		contentType string
+		body        string
+	}{
+		{"application/msgpack", strings.Repeat("\x91", 1<<20)},
+		{"application/yaml", "name: [" + strings.Repeat("[", 1<<20)},
+		{"application/yaml", "name: " + strings.Repeat("{a: ", 1<<18)},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/hello", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "exceeded max depth") {
+			t.Errorf("%s: expected 400 for deep body, got %d %.200s", test.contentType, w.Code, w.Body.String())
+		}
+	}
+}
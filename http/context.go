@@ -1,43 +1,175 @@
 package http
 
 import (
+	"bytes"
+	"sync"
 	"net/http"
 	"io/ioutil"
 	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+	"reflect"
+	"mime/multipart"
 )
 
 type Context struct {
 	Request        *http.Request
-	ResponseWriter http.ResponseWriter
+	ResponseWriter ResponseWriter
 
+	router       *Router
+	route        *route
 	metaData     map[string]interface{}
 	handlerIndex int
-	handlerChain HandlerChain
+	lastStarted  int
+	aborted      bool
+	handlerErr   error
+	invokers     []invoker
+	params       Params
 	hasReadBody  bool
 	body         []byte
-	hasResponse  bool
+	bodyErr      error
+	multipartErr error
+	// multipartForm is removed in release, after the response is written
+	multipartForm *multipart.Form
+	hasResponse   bool
 
 	responseData []byte
 	httpStatus   int
 	contentType  string
+	beforeWrite  []func(c *Context)
+	afterWrite   []func(c *Context)
+
+	writer responseWriter
+	// scratch is taken from jsonBufferPool by JsonRaw and put back in release
+	scratch *jsonBuffer
+}
+
+var jsonContentType = []string{"application/json;charset=UTF-8"}
+
+// maxPooledBuffer keeps the buffers of large responses from staying in the
+// pool.
+const maxPooledBuffer = 64 << 10
+
+// jsonBuffer is what JsonRaw encodes into. It is pooled rather than the
+// Context, so a Context kept after the request never shares memory with the
+// next one.
+type jsonBuffer struct {
+	buf     bytes.Buffer
+	encoder *json.Encoder
+}
+
+var jsonBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := new(jsonBuffer)
+		b.encoder = json.NewEncoder(&b.buf)
+		return b
+	},
+}
+
+func newContext(req *http.Request, w http.ResponseWriter, invokers []invoker) *Context {
+	c := &Context{Request: req, invokers: invokers, httpStatus: http.StatusOK}
+	c.writer = responseWriter{ResponseWriter: w, status: http.StatusOK, before: c.runBeforeWrite}
+	c.ResponseWriter = &c.writer
+	return c
+}
+
+// release ends the request once the response is written: uploaded files are
+// removed and the JSON buffer goes back to the pool. The Context stays
+// usable as the context.Context of the request.
+func (this *Context) release() {
+	if this.multipartForm != nil {
+		this.multipartForm.RemoveAll()
+		this.multipartForm = nil
+	}
+	if scratch := this.scratch; scratch != nil {
+		this.scratch = nil
+		this.responseData = nil
+		if scratch.buf.Cap() <= maxPooledBuffer {
+			jsonBufferPool.Put(scratch)
+		}
+	}
 }
 
-func newContext(req *http.Request, w http.ResponseWriter, chain HandlerChain) *Context {
-	return &Context{
-		Request:        req,
-		ResponseWriter: w,
-		metaData:       make(map[string]interface{}),
-		handlerChain:   chain,
-		handlerIndex:   0,
-		httpStatus:     http.StatusOK,
+// Copy returns a Context holding the request data of this one, for
+// goroutines running after the handler returned. The Context itself may be
+// kept as a context.Context, but its response is finished once the handlers
+// return, so writing to it races with that; what the copy writes is dropped.
+func (this *Context) Copy() *Context {
+	c := newContext(this.Request, detachedWriter{header: http.Header{}}, nil)
+	c.router = this.router
+	c.route = this.route
+	c.params = append(c.params, this.params...)
+	for key, value := range this.metaData {
+		c.SetMetaData(key, value)
 	}
+	c.hasReadBody = this.hasReadBody
+	c.body = this.body
+	c.bodyErr = this.bodyErr
+	return c
+}
+
+type detachedWriter struct {
+	header http.Header
+}
+
+func (w detachedWriter) Header() http.Header {
+	return w.header
 }
 
+func (w detachedWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w detachedWriter) WriteHeader(status int) {}
+
+// Next runs the rest of the chain and returns when it is done. Every handler
+// runs at most once, so calling Next again does nothing, and nothing runs
+// after Abort.
 func (this *Context) Next() {
-	this.handlerIndex++
-	if this.handlerIndex < len(this.handlerChain) {
-		this.processHandler()
+	caller := this.handlerIndex
+	next := caller + 1
+	if this.aborted || next >= len(this.invokers) || next <= this.lastStarted {
+		return
 	}
+	this.lastStarted = next
+	this.handlerIndex = next
+	this.processHandler()
+	this.handlerIndex = caller
+}
+
+// Abort keeps the handlers after the current one from running. The response
+// written so far is kept.
+func (this *Context) Abort() {
+	this.aborted = true
+}
+
+// AbortWithStatus answers status and aborts. Error statuses are answered with
+// the error envelope.
+func (this *Context) AbortWithStatus(status int) {
+	if status >= http.StatusBadRequest {
+		this.DieWithHttpStatus(status)
+	} else {
+		this.Data(status, "", nil)
+	}
+	this.Abort()
+}
+
+// AbortWithError answers err like Fail and aborts.
+func (this *Context) AbortWithError(err error) {
+	this.Fail(err)
+	this.Abort()
+}
+
+func (this *Context) IsAborted() bool {
+	return this.aborted
+}
+
+// HandlerError returns the last error answered by Fail, a handler or the
+// binding, so middleware can inspect it after Next. It is nil when the
+// request succeeded.
+func (this *Context) HandlerError() error {
+	return this.handlerErr
 }
 
 func (this *Context) GetUrlParam(key string) string {
@@ -48,6 +180,11 @@ func (this *Context) GetUrlParam(key string) string {
 	return ""
 }
 
+// Param returns the value of a path parameter, e.g. "id" for "/users/:id".
+func (this *Context) Param(key string) string {
+	return this.params.ByName(key)
+}
+
 func (this *Context) GetHeader(key string) string {
 	return this.Request.Header.Get(key)
 }
@@ -63,40 +200,200 @@ func (this *Context) SetMetaData(key string, value interface{}) {
 	this.metaData[key] = value
 }
 
+// GetAs returns the meta data of key as a T, ok is false when it is missing
+// or of another type. It is not named Get, which is the http client's.
+func GetAs[T any](c *Context, key string) (value T, ok bool) {
+	value, ok = c.metaData[key].(T)
+	return value, ok
+}
+
+// MustGetAs returns the meta data of key as a T and panics when it is missing
+// or of another type.
+func MustGetAs[T any](c *Context, key string) T {
+	value, ok := GetAs[T](c, key)
+	if !ok {
+		panic(fmt.Sprintf("http: meta data %q is %T, not %v", key, c.metaData[key], reflect.TypeOf((*T)(nil)).Elem()))
+	}
+	return value
+}
+
+// Json answers data wrapped in the envelope of the router's Renderer.
 func (this *Context) Json(data interface{}) {
-	res, _ := json.Marshal(map[string]interface{}{
-		"status": 0,
-		"data":   data,
-	})
-	this.responseData = res
+	this.JsonRaw(this.renderer().Success(this, data))
+}
+
+// JsonRaw answers data as is, without the envelope.
+func (this *Context) JsonRaw(data interface{}) {
+	// encode into a pooled buffer instead of a new slice
+	if this.scratch == nil {
+		this.scratch = jsonBufferPool.Get().(*jsonBuffer)
+	}
+	this.scratch.buf.Reset()
+	this.responseData = nil
+	if err := this.scratch.encoder.Encode(data); err == nil {
+		this.responseData = bytes.TrimSuffix(this.scratch.buf.Bytes(), []byte("\n"))
+	}
 	this.hasResponse = true
-	this.contentType = "application/json;charset=UTF-8"
+	this.contentType = jsonContentType[0]
+}
 
+// emptyResponse answers with an empty object when a handler wrote nothing.
+func (this *Context) emptyResponse() {
+	if !this.hasResponse {
+		this.Json(struct{}{})
+	}
 }
 
+// DieWithHttpStatus answers the error envelope for an http status.
 func (this *Context) DieWithHttpStatus(status int) {
+	this.writeError(status, &ErrorResponse{
+		Status:  status,
+		Message: http.StatusText(status),
+	})
+}
+
+// validationFailed answers 400 listing the failing fields in Data.
+func (this *Context) validationFailed(errs ValidationErrors) {
+	this.writeError(http.StatusBadRequest, &ErrorResponse{
+		Status:  http.StatusBadRequest,
+		Message: "validation failed",
+		Data:    errs,
+	})
+}
+
+func (this *Context) writeError(status int, err *ErrorResponse) {
+	this.handlerErr = err
+	this.render(status, this.renderer().Error(this, status, err))
+}
+
+// Status sets the http status of the response, the body written by Json,
+// Render or the others goes out with it.
+func (this *Context) Status(code int) *Context {
+	this.httpStatus = code
+	return this
+}
+
+func (this *Context) SetHeader(key, value string) {
+	this.ResponseWriter.Header().Set(key, value)
+}
+
+func (this *Context) AddHeader(key, value string) {
+	this.ResponseWriter.Header().Add(key, value)
+}
+
+// Redirect answers a redirect to location, code is 201 or a 3xx status.
+func (this *Context) Redirect(code int, location string) {
+	if (code < http.StatusMultipleChoices || code > http.StatusPermanentRedirect) && code != http.StatusCreated {
+		panic(fmt.Sprintf("http: cannot redirect with status code %d", code))
+	}
+	this.SetHeader("Location", location)
+	this.Data(code, "", nil)
+}
+
+// Data answers data as is with the given content type.
+func (this *Context) Data(status int, contentType string, data []byte) {
 	this.httpStatus = status
+	this.contentType = contentType
+	this.responseData = data
 	this.hasResponse = true
-	this.contentType = "text/plain;charset=UTF-8"
 }
 
+// NoContent answers 204 without a body.
+func (this *Context) NoContent() {
+	this.Data(http.StatusNoContent, "", nil)
+}
+
+// response writes the headers, the status and then the body, unless a
+// handler wrote to the ResponseWriter itself.
 func (this *Context) response() {
-	if this.contentType != "" {
-		this.ResponseWriter.Header().Add("Content-Type", this.contentType)
+	if this.ResponseWriter.Written() {
+		return
 	}
-	if this.httpStatus == http.StatusOK {
+	this.httpStatus = this.runBeforeWrite(this.httpStatus)
+	header := this.ResponseWriter.Header()
+	switch this.contentType {
+	case "":
+	case jsonContentType[0]:
+		// a shared slice saves an allocation on every JSON response
+		header["Content-Type"] = jsonContentType
+	default:
+		header.Set("Content-Type", this.contentType)
+	}
+	if this.responseData != nil && bodyAllowed(this.httpStatus) && header.Get("Content-Length") == "" {
+		header.Set("Content-Length", strconv.Itoa(len(this.responseData)))
+	}
+	this.ResponseWriter.WriteHeader(this.httpStatus)
+	if this.Request.Method != http.MethodHead && len(this.responseData) > 0 && bodyAllowed(this.httpStatus) {
 		this.ResponseWriter.Write(this.responseData)
-	} else {
-		this.ResponseWriter.WriteHeader(this.httpStatus)
 	}
+}
 
+// bodyAllowed reports whether a response with status may have a body.
+func bodyAllowed(status int) bool {
+	return status >= http.StatusOK && status != http.StatusNoContent && status != http.StatusNotModified
 }
 
 func (this *Context) Body() []byte {
+	body, _ := this.ReadBody()
+	return body
+}
+
+// ReadBody reads the request body once, limited to the max body size of the
+// route or router. Reading past the limit returns *http.MaxBytesError.
+func (this *Context) ReadBody() ([]byte, error) {
 	if !this.hasReadBody {
-		body, _ := ioutil.ReadAll(this.Request.Body)
 		this.hasReadBody = true
-		this.body = body
+		reader := this.Request.Body
+		if limit := this.maxBodySize(); limit > 0 && reader != nil {
+			if this.Request.ContentLength > limit {
+				this.bodyErr = &http.MaxBytesError{Limit: limit}
+				return nil, this.bodyErr
+			}
+			reader = http.MaxBytesReader(this.ResponseWriter, reader, limit)
+		}
+		if reader != nil {
+			this.body, this.bodyErr = ioutil.ReadAll(reader)
+		}
+	}
+	return this.body, this.bodyErr
+}
+
+func (this *Context) maxBodySize() int64 {
+	if this.route != nil && this.route.maxBodySize > 0 {
+		return this.route.maxBodySize
+	}
+	if this.router != nil {
+		return this.router.maxBodySize
+	}
+	return 0
+}
+
+func (this *Context) disallowUnknownFields() bool {
+	return (this.route != nil && this.route.disallowUnknownFields) ||
+		(this.router != nil && this.router.disallowUnknownFields)
+}
+
+// Deadline, Done, Err and Value make *Context a context.Context of the
+// request, so it can be passed to calls which should stop with the request.
+func (this *Context) Deadline() (time.Time, bool) {
+	return this.Request.Context().Deadline()
+}
+
+func (this *Context) Done() <-chan struct{} {
+	return this.Request.Context().Done()
+}
+
+func (this *Context) Err() error {
+	return this.Request.Context().Err()
+}
+
+// Value returns the meta data set for string keys, then the values of the
+// request context.
+func (this *Context) Value(key interface{}) interface{} {
+	if name, ok := key.(string); ok {
+		if value, exist := this.metaData[name]; exist {
+			return value
+		}
 	}
-	return this.body
+	return this.Request.Context().Value(key)
 }
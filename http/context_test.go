@@ -0,0 +1,282 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContext_Response(t *testing.T) {
+	router := New()
+	router.POST("/users", func(c *Context) {
+		c.SetHeader("Location", "/users/1")
+		c.Status(http.StatusCreated).Json(map[string]int{"id": 1})
+	})
+	router.POST("/jobs", func(c *Context) {
+		c.AddHeader("X-Job", "a")
+		c.AddHeader("X-Job", "b")
+		c.Status(http.StatusAccepted).Render(http.StatusAccepted, "job-1")
+	})
+	router.GET("/old", func(c *Context) {
+		c.Redirect(http.StatusFound, "/new")
+	})
+	router.GET("/csv", func(c *Context) {
+		c.Data(http.StatusOK, "text/csv", []byte("id\n1\n"))
+	})
+	router.DELETE("/users/:id", func(c *Context) {
+		c.NoContent()
+	})
+	router.POST("/orders", func(in *benchIn, out *benchOut, c *Context) error {
+		out.Name = in.Name
+		c.Status(http.StatusCreated)
+		return nil
+	})
+	router.POST("/typed/orders", Handle(func(c *Context, in *benchIn) (*benchOut, error) {
+		c.Status(http.StatusCreated)
+		return &benchOut{Name: in.Name}, nil
+	}))
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		header map[string]string
+		body   string
+	}{
+		{http.MethodPost, "/users", http.StatusCreated, map[string]string{"Location": "/users/1"}, `{"data":{"id":1},"status":0}`},
+		{http.MethodPost, "/jobs", http.StatusAccepted, nil, `{"data":"job-1","status":0}`},
+		{http.MethodGet, "/old", http.StatusFound, map[string]string{"Location": "/new"}, ``},
+		{http.MethodGet, "/csv", http.StatusOK, map[string]string{"Content-Type": "text/csv", "Content-Length": "5"}, "id\n1\n"},
+		{http.MethodHead, "/csv", http.StatusOK, map[string]string{"Content-Length": "5"}, ""},
+		{http.MethodDelete, "/users/1", http.StatusNoContent, map[string]string{"Content-Type": ""}, ``},
+		{http.MethodPost, "/orders", http.StatusCreated, nil, `{"data":{"id":0,"name":"pen"},"status":0}`},
+		{http.MethodPost, "/typed/orders", http.StatusCreated, nil, `{"data":{"id":0,"name":"pen"},"status":0}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, strings.NewReader(`{"name":"pen"}`)))
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.status, w.Code)
+		}
+		for key, value := range test.header {
+			if got := w.Header().Get(key); got != value {
+				t.Errorf("%s %s: expected header %s %q, got %q", test.method, test.path, key, value, got)
+			}
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s %s: unexpected body %q", test.method, test.path, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/jobs", nil))
+	if jobs := w.Header()["X-Job"]; len(jobs) != 2 {
+		t.Errorf("expected 2 X-Job headers, got %v", jobs)
+	}
+}
+
+func TestContext_RedirectInvalidCode(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for redirect with 200")
+		}
+	}()
+	c := newContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder(), nil)
+	c.Redirect(http.StatusOK, "/")
+}
+
+type ctxKey struct{}
+
+func TestContext_Context(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(context.WithValue(req.Context(), ctxKey{}, "from request"))
+	c := newContext(req, httptest.NewRecorder(), nil)
+	c.SetMetaData("user", "Lywane")
+
+	var ctx context.Context = c
+	if ctx.Value("user") != "Lywane" || ctx.Value(ctxKey{}) != "from request" || ctx.Value("missing") != nil {
+		t.Errorf("unexpected values %v %v", ctx.Value("user"), ctx.Value(ctxKey{}))
+	}
+	if _, ok := ctx.Deadline(); ok || ctx.Err() != nil {
+		t.Error("expected no deadline")
+	}
+
+	cancelCtx, cancel := context.WithCancel(req.Context())
+	c.Request = req.WithContext(cancelCtx)
+	cancel()
+	select {
+	case <-c.Done():
+	default:
+		t.Error("expected Done to be closed")
+	}
+	if c.Err() != context.Canceled {
+		t.Errorf("unexpected err %v", c.Err())
+	}
+}
+
+func TestDeadlineHandler(t *testing.T) {
+	router := New()
+	router.GET("/slow", DeadlineHandler(10*time.Millisecond), func(c *Context) error {
+		select {
+		case <-c.Done():
+			return c.Err()
+		case <-time.After(time.Second):
+		}
+		c.Json("done")
+		return nil
+	})
+	router.GET("/fast", DeadlineHandler(time.Second), func(c *Context) {
+		if _, ok := c.Deadline(); !ok {
+			t.Error("expected a deadline")
+		}
+		c.Json("done")
+	})
+	router.GET("/upstream", func(c *Context) error {
+		ctx, cancel := context.WithTimeout(c, time.Millisecond)
+		defer cancel()
+		<-ctx.Done()
+		return fmt.Errorf("query users: %w", ctx.Err())
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/slow", http.StatusServiceUnavailable, `{"status":503,"message":"request timed out"}`},
+		{"/fast", http.StatusOK, `{"data":"done","status":0}`},
+		{"/upstream", http.StatusGatewayTimeout, `{"status":504,"message":"query users: context deadline exceeded"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("%s: unexpected %d %s", test.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestContext_Pool(t *testing.T) {
+	copies := make(chan *Context, 1)
+	router := New()
+	router.GET("/users/:id", func(c *Context) {
+		if c.GetMetaData("user") != nil {
+			t.Error("meta data leaked from the previous request")
+		}
+		c.SetMetaData("user", c.Param("id"))
+		c.OnBeforeWrite(func(c *Context) {
+			c.SetHeader("X-User", c.Param("id"))
+		})
+		copies <- c.Copy()
+		c.Json(c.Param("id"))
+	})
+
+	for _, id := range []string{"1", "2"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+id, nil))
+		if w.Body.String() != `{"data":"`+id+`","status":0}` || w.Header().Get("X-User") != id {
+			t.Errorf("unexpected response %v %s", w.Header(), w.Body.String())
+		}
+		copied := <-copies
+		if copied.Param("id") != id || copied.GetMetaData("user") != id {
+			t.Errorf("copy lost the request data: %s %v", copied.Param("id"), copied.GetMetaData("user"))
+		}
+	}
+}
+
+type contextKey struct{}
+
+func TestContext_AfterServeHTTP(t *testing.T) {
+	kept := make(chan *Context, 1)
+	router := New()
+	router.GET("/users/:id", func(c *Context) {
+		c.SetMetaData("user", c.Param("id"))
+		if c.Param("id") == "1" {
+			kept <- c
+		}
+		c.Json(c.Param("id"))
+	})
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), contextKey{}, "trace"))
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(ctx))
+	c := <-kept
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		<-c.Done()
+		if _, ok := c.Deadline(); ok || c.Err() != context.Canceled ||
+			c.Value("user") != "1" || c.Value(contextKey{}) != "trace" {
+			t.Errorf("unexpected context after the request %v %v %v", c.Err(), c.Value("user"), c.Value(contextKey{}))
+		}
+	}()
+	// the next requests must not touch the kept Context
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/2", nil))
+		if w.Body.String() != `{"data":"2","status":0}` {
+			t.Errorf("unexpected response %s", w.Body.String())
+		}
+	}
+	cancel()
+	<-done
+}
+
+func TestContext_Abort(t *testing.T) {
+	var ran []string
+	var handlerErr error
+	router := New()
+	router.Use(func(c *Context) {
+		c.Next()
+		c.Next()
+		handlerErr = c.HandlerError()
+	})
+	router.GET("/users/:id", func(c *Context) {
+		ran = append(ran, "auth")
+		switch c.Param("id") {
+		case "0":
+			c.AbortWithStatus(http.StatusUnauthorized)
+			c.Next()
+		case "1":
+			c.Abort()
+			c.NoContent()
+		case "2":
+			c.AbortWithError(fmt.Errorf("lookup failed"))
+		default:
+			c.Next()
+		}
+	}, func(c *Context) {
+		ran = append(ran, "next")
+		c.Next()
+	}, func(c *Context) *ErrorResponse {
+		ran = append(ran, "handler")
+		return &ErrorResponse{Status: http.StatusNotFound, Message: "user not found"}
+	})
+
+	tests := []struct {
+		id     string
+		status int
+		ran    string
+		err    string
+	}{
+		{"0", http.StatusUnauthorized, "auth", "Unauthorized"},
+		{"1", http.StatusNoContent, "auth", ""},
+		{"2", http.StatusInternalServerError, "auth", "lookup failed"},
+		{"3", http.StatusNotFound, "auth next handler", "user not found"},
+	}
+	for _, test := range tests {
+		ran, handlerErr = nil, nil
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/"+test.id, nil))
+		if w.Code != test.status || fmt.Sprint(ran) != "["+test.ran+"]" {
+			t.Errorf("%s: unexpected %d %v", test.id, w.Code, ran)
+		}
+		if (handlerErr == nil) != (test.err == "") || (handlerErr != nil && handlerErr.Error() != test.err) {
+			t.Errorf("%s: unexpected error %v", test.id, handlerErr)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package http
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures CORSHandler.
+type CORSConfig struct {
+	// AllowOrigins are the origins allowed to call, e.g.
+	// "https://example.com". "*" allows every origin and one "*" inside an
+	// origin matches any part, e.g. "https://*.example.com".
+	AllowOrigins []string
+	// AllowOriginRegexps are regular expressions matched against the whole
+	// origin as sent, in addition to AllowOrigins.
+	AllowOriginRegexps []string
+	// AllowMethods are answered to preflight requests. GET, HEAD, POST, PUT,
+	// PATCH and DELETE by default.
+	AllowMethods []string
+	// AllowHeaders are the request headers allowed by preflight requests.
+	// None allows the headers the preflight asks for.
+	AllowHeaders []string
+	// ExposeHeaders are the response headers scripts may read.
+	ExposeHeaders []string
+	// AllowCredentials allows cookies and authorization headers. It needs
+	// explicit origins, CORSHandler panics when AllowOrigins has "*".
+	AllowCredentials bool
+	// MaxAge is how long preflight answers may be cached, 0 leaves it to the
+	// browser.
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+type corsPolicy struct {
+	anyOrigin     bool
+	origins       map[string]bool
+	wildcards     [][2]string
+	regexps       []*regexp.Regexp
+	methods       string
+	headers       string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
+
+// CORSHandler answers cross-origin requests from the allowed origins.
+// Preflight requests are answered with 204 before the route handlers run,
+// also for paths only the automatic OPTIONS answers, as long as the handler is
+// used on the router rather than a group. Requests from other origins get no
+// CORS headers, their preflight is answered with 403.
+//
+//	router.Use(CORSHandler(CORSConfig{
+//		AllowOrigins:     []string{"https://example.com", "https://*.example.com"},
+//		AllowCredentials: true,
+//		MaxAge:           time.Hour,
+//	}))
+func CORSHandler(config CORSConfig) Handler {
+	cors := &corsPolicy{origins: make(map[string]bool), credentials: config.AllowCredentials}
+	for _, origin := range config.AllowOrigins {
+		switch i := strings.IndexByte(origin, '*'); {
+		case origin == "*":
+			cors.anyOrigin = true
+		case i >= 0:
+			cors.wildcards = append(cors.wildcards, [2]string{strings.ToLower(origin[:i]), strings.ToLower(origin[i+1:])})
+		default:
+			cors.origins[strings.ToLower(origin)] = true
+		}
+	}
+	if cors.anyOrigin && cors.credentials {
+		panic("http: CORS credentials cannot be allowed for any origin")
+	}
+	for _, expr := range config.AllowOriginRegexps {
+		cors.regexps = append(cors.regexps, regexp.MustCompile("^(?:"+expr+")$"))
+	}
+	methods := config.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	cors.methods = strings.ToUpper(strings.Join(methods, ", "))
+	cors.headers = strings.Join(config.AllowHeaders, ", ")
+	cors.exposeHeaders = strings.Join(config.ExposeHeaders, ", ")
+	if config.MaxAge > 0 {
+		cors.maxAge = strconv.FormatInt(int64(config.MaxAge/time.Second), 10)
+	}
+	return cors.handle
+}
+
+func (cors *corsPolicy) handle(c *Context) {
+	origin := c.Request.Header.Get("Origin")
+	if origin == "" {
+		c.Next()
+		return
+	}
+	header := c.ResponseWriter.Header()
+	header.Add("Vary", "Origin")
+	preflight := c.Request.Method == http.MethodOptions && c.Request.Header.Get("Access-Control-Request-Method") != ""
+	if !cors.allowed(origin) {
+		if preflight {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Next()
+		return
+	}
+
+	if cors.anyOrigin {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cors.credentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if cors.exposeHeaders != "" {
+			header.Set("Access-Control-Expose-Headers", cors.exposeHeaders)
+		}
+		c.Next()
+		return
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", cors.methods)
+	if headers := cors.headers; headers != "" {
+		header.Set("Access-Control-Allow-Headers", headers)
+	} else if requested := c.Request.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if cors.maxAge != "" {
+		header.Set("Access-Control-Max-Age", cors.maxAge)
+	}
+	c.Abort()
+	c.NoContent()
+}
+
+func (cors *corsPolicy) allowed(origin string) bool {
+	if cors.anyOrigin {
+		return true
+	}
+	lower := strings.ToLower(origin)
+	if cors.origins[lower] {
+		return true
+	}
+	for _, wildcard := range cors.wildcards {
+		if len(lower) > len(wildcard[0])+len(wildcard[1]) &&
+			strings.HasPrefix(lower, wildcard[0]) && strings.HasSuffix(lower, wildcard[1]) {
+			return true
+		}
+	}
+	for _, re := range cors.regexps {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSHandler(t *testing.T) {
+	var ran int
+	router := New()
+	router.Use(CORSHandler(CORSConfig{
+		AllowOrigins:       []string{"https://example.com", "https://*.example.org"},
+		AllowOriginRegexps: []string{`http://localhost:\d+`, `https://Preview-\d+\.example\.net`},
+		AllowMethods:       []string{"GET", "PUT"},
+		ExposeHeaders:      []string{"X-Total"},
+		AllowCredentials:   true,
+		MaxAge:             10 * time.Minute,
+	}))
+	router.GET("/users", func(c *Context) {
+		ran++
+		c.Json("users")
+	})
+	router.OPTIONS("/items", func(c *Context) {
+		ran++
+		c.Json("options")
+	})
+
+	preflight := map[string]string{"Access-Control-Request-Method": "PUT", "Access-Control-Request-Headers": "X-Token"}
+	tests := []struct {
+		method string
+		path   string
+		origin string
+		header map[string]string
+		status int
+		expect map[string]string
+		ran    int
+	}{
+		{http.MethodGet, "/users", "", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": ""}, 1},
+		{http.MethodGet, "/users", "https://example.com", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers": "X-Total", "Vary": "Origin"}, 1},
+		{http.MethodGet, "/users", "https://api.example.org", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"}, 1},
+		{http.MethodGet, "/users", "http://localhost:3000", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "http://localhost:3000"}, 1},
+		{http.MethodGet, "/users", "https://Preview-7.example.net", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": "https://Preview-7.example.net"}, 1},
+		{http.MethodGet, "/users", "https://evil.com", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Origin": ""}, 1},
+		{http.MethodOptions, "/users", "https://example.com", preflight, http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Origin": "https://example.com", "Access-Control-Allow-Methods": "GET, PUT",
+				"Access-Control-Allow-Headers": "X-Token", "Access-Control-Max-Age": "600", "Access-Control-Expose-Headers": ""}, 0},
+		{http.MethodOptions, "/items", "https://example.com", preflight, http.StatusNoContent,
+			map[string]string{"Access-Control-Allow-Methods": "GET, PUT"}, 0},
+		{http.MethodOptions, "/items", "https://example.com", nil, http.StatusOK,
+			map[string]string{"Access-Control-Allow-Methods": ""}, 1},
+		{http.MethodOptions, "/users", "https://evil.com", preflight, http.StatusForbidden,
+			map[string]string{"Access-Control-Allow-Origin": ""}, 0},
+		{http.MethodOptions, "/users", "", nil, http.StatusNoContent,
+			map[string]string{"Allow": "GET, HEAD, OPTIONS"}, 0},
+	}
+	for _, test := range tests {
+		ran = 0
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(test.method, test.path, nil)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		for key, value := range test.header {
+			req.Header.Set(key, value)
+		}
+		router.ServeHTTP(w, req)
+		if w.Code != test.status || ran != test.ran {
+			t.Errorf("%s %s %s: unexpected %d, handler ran %d times", test.method, test.path, test.origin, w.Code, ran)
+		}
+		for key, value := range test.expect {
+			if got := w.Header().Get(key); got != value {
+				t.Errorf("%s %s %s: expected header %s %q, got %q", test.method, test.path, test.origin, key, value, got)
+			}
+		}
+	}
+}
+
+func TestCORSHandler_AnyOrigin(t *testing.T) {
+	router := New()
+	router.Use(CORSHandler(CORSConfig{AllowOrigins: []string{"*"}, AllowHeaders: []string{"Content-Type"}}))
+	router.POST("/events", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodOptions, "/events", nil)
+	req.Header.Set("Origin", "https://anywhere.io")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "X-Token")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" ||
+		w.Header().Get("Access-Control-Allow-Headers") != "Content-Type" ||
+		w.Header().Get("Access-Control-Allow-Methods") != "GET, HEAD, POST, PUT, PATCH, DELETE" ||
+		w.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("unexpected preflight %d %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSHandler_AnyOriginCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for credentials with any origin")
+		}
+	}()
+	CORSHandler(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+)
+
+// StatusCoder is implemented by errors knowing their http status.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Coder is implemented by errors knowing their business code, the status
+// field of the error envelope.
+type Coder interface {
+	Code() int
+}
+
+// ErrorResponse is the error envelope. Status is the business code, the http
+// status is HttpStatus, or Status when it is an http error status, or 400.
+type ErrorResponse struct {
+	XMLName    xml.Name    `json:"-" xml:"error"`
+	Status     int         `json:"status" xml:"status"`
+	Message    string      `json:"message,omitempty" xml:"message,omitempty"`
+	Data       interface{} `json:"data,omitempty" xml:"data,omitempty"`
+	HttpStatus int         `json:"-" xml:"-"`
+}
+
+func ReturnError(status int, err error) *ErrorResponse {
+	return &ErrorResponse{
+		Status:  status,
+		Message: err.Error(),
+	}
+}
+
+func (e *ErrorResponse) Error() string {
+	return e.Message
+}
+
+func (e *ErrorResponse) StatusCode() int {
+	switch {
+	case e.HttpStatus != 0:
+		return e.HttpStatus
+	case e.Status >= 400 && e.Status < 600:
+		return e.Status
+	}
+	return http.StatusBadRequest
+}
+
+func (e *ErrorResponse) Code() int {
+	return e.Status
+}
+
+// WithHttpStatus sets the http status the error is answered with.
+func (e *ErrorResponse) WithHttpStatus(status int) *ErrorResponse {
+	e.HttpStatus = status
+	return e
+}
+
+// Fail answers with the error envelope of err. *ErrorResponse is written as
+// is, other errors get their http status from StatusCode() and their status
+// field from Code(), found with errors.As. The defaults are 500 and the http
+// status, 504 for context.DeadlineExceeded.
+func (this *Context) Fail(err error) {
+	if err == nil {
+		return
+	}
+	this.fail(err)
+	this.handlerErr = err
+}
+
+func (this *Context) fail(err error) {
+	var res *ErrorResponse
+	if errors.As(err, &res) && res != nil {
+		this.writeError(res.StatusCode(), res)
+		return
+	}
+	var validErrs ValidationErrors
+	if errors.As(err, &validErrs) {
+		this.validationFailed(validErrs)
+		return
+	}
+	if isBindError(err) {
+		this.bindFailed(err)
+		return
+	}
+
+	status := http.StatusInternalServerError
+	if errors.Is(err, context.DeadlineExceeded) {
+		status = http.StatusGatewayTimeout
+	}
+	var statusCoder StatusCoder
+	if errors.As(err, &statusCoder) {
+		status = statusCoder.StatusCode()
+	}
+	code := status
+	var coder Coder
+	if errors.As(err, &coder) {
+		code = coder.Code()
+	}
+	this.writeError(status, &ErrorResponse{
+		Status:  code,
+		Message: err.Error(),
+	})
+}
+
+// isNilError reports whether err is nil or holds a nil *ErrorResponse, which
+// handlers returning error may do by accident.
+func isNilError(err error) bool {
+	if err == nil {
+		return true
+	}
+	res, ok := err.(*ErrorResponse)
+	return ok && res == nil
+}
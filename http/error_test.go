@@ -0,0 +1,60 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type notFoundError struct {
+	id string
+}
+
+func (e *notFoundError) Error() string   { return "user " + e.id + " not found" }
+func (e *notFoundError) StatusCode() int { return http.StatusNotFound }
+func (e *notFoundError) Code() int       { return 10404 }
+
+func TestContext_Fail(t *testing.T) {
+	router := New()
+	router.GET("/plain", func(c *Context) error {
+		return errors.New("boom")
+	})
+	router.GET("/mapped", func(in *In, out *Out) error {
+		return fmt.Errorf("load: %w", &notFoundError{id: "42"})
+	})
+	router.GET("/response", func(in *In, out *Out, c *Context) *ErrorResponse {
+		return ReturnError(1001, errors.New("name is taken"))
+	})
+	router.GET("/http-status", func(c *Context) *ErrorResponse {
+		return &ErrorResponse{Status: http.StatusForbidden, Message: "forbidden"}
+	})
+	router.GET("/with-status", func(c *Context) error {
+		return ReturnError(1002, errors.New("locked")).WithHttpStatus(http.StatusLocked)
+	})
+	router.GET("/typed-nil", func(c *Context) error {
+		var res *ErrorResponse
+		return res
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/plain", http.StatusInternalServerError, `{"status":500,"message":"boom"}`},
+		{"/mapped", http.StatusNotFound, `{"status":10404,"message":"load: user 42 not found"}`},
+		{"/response", http.StatusBadRequest, `{"status":1001,"message":"name is taken"}`},
+		{"/http-status", http.StatusForbidden, `{"status":403,"message":"forbidden"}`},
+		{"/with-status", http.StatusLocked, `{"status":1002,"message":"locked"}`},
+		{"/typed-nil", http.StatusOK, `{"data":{},"status":0}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("%s: expected %d %s, got %d %s", test.path, test.status, test.body, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// invoker runs one compiled handler against a Context.
+type invoker func(c *Context)
+
+type route struct {
+	method string
+	path   string
+	name   string
+	group  *Router
+	// own holds the handlers passed at registration, handlers the full chain
+	// including the middleware of every enclosing group.
+	own      HandlerChain
+	handlers HandlerChain
+	invokers []invoker
+
+	maxBodySize           int64
+	disallowUnknownFields bool
+	upload                *UploadConfig
+}
+
+var (
+	contextType       = reflect.TypeOf(&Context{})
+	errorResponseType = reflect.TypeOf(&ErrorResponse{})
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// compileHandler checks the shape of handler once and returns an invoker that
+// runs it without inspecting its type again.
+func compileHandler(handler Handler, providers map[reflect.Type]provider) (invoker, error) {
+	switch h := handler.(type) {
+	case func(*Context):
+		return func(c *Context) {
+			h(c)
+			c.emptyResponse()
+		}, nil
+	case func(*Context) *ErrorResponse:
+		return func(c *Context) {
+			if err := h(c); err != nil {
+				c.AbortWithError(err)
+				return
+			}
+			c.emptyResponse()
+		}, nil
+	case func(*Context) error:
+		return func(c *Context) {
+			if err := h(c); !isNilError(err) {
+				c.AbortWithError(err)
+				return
+			}
+			c.emptyResponse()
+		}, nil
+	case typedHandler:
+		return h.compile()
+	}
+
+	handlerType := reflect.TypeOf(handler)
+	if handlerType == nil || handlerType.Kind() != reflect.Func {
+		return nil, fmt.Errorf("handler must be a func, got %v", handlerType)
+	}
+	if handlerType.IsVariadic() {
+		return nil, errors.New("handler must not be variadic")
+	}
+	switch handlerType.NumOut() {
+	case 0:
+	case 1:
+		if handlerType.Out(0) != errorResponseType && handlerType.Out(0) != errorType {
+			return nil, fmt.Errorf("handler must return *ErrorResponse or error, got %v", handlerType.Out(0))
+		}
+	default:
+		return nil, fmt.Errorf("handler must return at most one value, got %d", handlerType.NumOut())
+	}
+
+	if handlerType.NumIn() < 2 {
+		return nil, fmt.Errorf("handler must be func(*Context) or func(in, out, ...), got %v", handlerType)
+	}
+	inType, outType := handlerType.In(0), handlerType.In(1)
+	if inType.Kind() != reflect.Ptr || inType.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("handler in param must be a pointer to struct, got %v", inType)
+	}
+	if outType.Kind() != reflect.Ptr {
+		return nil, fmt.Errorf("handler out param must be a pointer, got %v", outType)
+	}
+	injectors, err := injectorsOf(handlerType, providers)
+	if err != nil {
+		return nil, err
+	}
+	hasResult := handlerType.NumOut() == 1
+
+	fn := reflect.ValueOf(handler)
+	inElem, outElem := inType.Elem(), outType.Elem()
+	plan, err := validPlanOf(inElem)
+	if err != nil {
+		return nil, err
+	}
+	return func(c *Context) {
+		args := make([]reflect.Value, 2, 2+len(injectors))
+		for _, inject := range injectors {
+			value, err := inject(c)
+			if err != nil {
+				c.AbortWithError(err)
+				return
+			}
+			args = append(args, value)
+		}
+		in := reflect.New(inElem)
+		if err := c.Bind(in.Interface()); err != nil {
+			c.bindFailed(err)
+			c.Abort()
+			return
+		}
+		if !plan.empty() {
+			var errs ValidationErrors
+			plan.validate(in.Elem(), "", &errs)
+			if len(errs) > 0 {
+				c.validationFailed(errs)
+				c.Abort()
+				return
+			}
+		}
+		out := reflect.New(outElem)
+		args[0], args[1] = in, out
+		results := fn.Call(args)
+		if hasResult && !results[0].IsNil() {
+			if err := results[0].Interface().(error); !isNilError(err) {
+				c.AbortWithError(err)
+				return
+			}
+		}
+		c.Render(c.httpStatus, out.Interface())
+	}, nil
+}
+
+func compileChain(handlers HandlerChain, providers map[reflect.Type]provider) ([]invoker, error) {
+	invokers := make([]invoker, len(handlers))
+	for i, handler := range handlers {
+		compiled, err := compileHandler(handler, providers)
+		if err != nil {
+			return nil, fmt.Errorf("handler #%d (%T): %v", i, handler, err)
+		}
+		invokers[i] = compiled
+	}
+	return invokers, nil
+}
@@ -0,0 +1,500 @@
+package http
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// marshalMsgPack encodes v as MessagePack following the json tags of v.
+// []byte and json.RawMessage are written as bin, values implementing
+// json.Marshaler or encoding.TextMarshaler go through encoding/json.
+func marshalMsgPack(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeMsgPackValue(&b, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeMsgPackValue(b *bytes.Buffer, v reflect.Value) error {
+	if !v.IsValid() {
+		b.WriteByte(0xc0)
+		return nil
+	}
+	t := v.Type()
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		if v.IsNil() {
+			b.WriteByte(0xc0)
+			return nil
+		}
+		writeMsgPackHeader(b, v.Len(), 0, -1, 0xc4, 0xc5, 0xc6)
+		b.Write(v.Bytes())
+		return nil
+	}
+	if (t.Kind() != reflect.Ptr || !v.IsNil()) &&
+		(t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+			(v.CanAddr() && (reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)))) {
+		return writeMsgPackJSON(b, v)
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			b.WriteByte(0xc0)
+			return nil
+		}
+		return writeMsgPackValue(b, v.Elem())
+	case reflect.Bool:
+		return writeMsgPack(b, v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		writeMsgPackInt(b, v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n := v.Uint(); n > math.MaxInt64 {
+			b.WriteByte(0xcf)
+			binary.Write(b, binary.BigEndian, n)
+		} else {
+			writeMsgPackInt(b, int64(n))
+		}
+	case reflect.Float32, reflect.Float64:
+		b.WriteByte(0xcb)
+		binary.Write(b, binary.BigEndian, math.Float64bits(v.Float()))
+	case reflect.String:
+		return writeMsgPack(b, v.String())
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			b.WriteByte(0xc0)
+			return nil
+		}
+		writeMsgPackHeader(b, v.Len(), 0x90, 15, 0, 0xdc, 0xdd)
+		for i := 0; i < v.Len(); i++ {
+			if err := writeMsgPackValue(b, v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.IsNil() {
+			b.WriteByte(0xc0)
+			return nil
+		}
+		return writeMsgPackMap(b, v)
+	case reflect.Struct:
+		fields := make(map[string]reflect.Value)
+		var names []string
+		collectMsgPackFields(v, fields, &names)
+		writeMsgPackHeader(b, len(names), 0x80, 15, 0, 0xde, 0xdf)
+		for _, name := range names {
+			writeMsgPack(b, name)
+			if err := writeMsgPackValue(b, fields[name]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %v", t)
+	}
+	return nil
+}
+
+// writeMsgPackJSON writes v as encoding/json would see it.
+func writeMsgPackJSON(b *bytes.Buffer, v reflect.Value) error {
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return err
+	}
+	return writeMsgPack(b, tree)
+}
+
+// writeMsgPackMap writes the keys of v in order, formatted like
+// encoding/json does.
+func writeMsgPackMap(b *bytes.Buffer, v reflect.Value) error {
+	keys := make([]string, 0, v.Len())
+	values := make(map[string]reflect.Value, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := msgPackKey(iter.Key())
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		values[key] = iter.Value()
+	}
+	sort.Strings(keys)
+	writeMsgPackHeader(b, len(keys), 0x80, 15, 0, 0xde, 0xdf)
+	for _, key := range keys {
+		writeMsgPack(b, key)
+		if err := writeMsgPackValue(b, values[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func msgPackKey(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+	if marshaler, ok := key.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	switch key.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("msgpack: unsupported map key type %v", key.Type())
+}
+
+// collectMsgPackFields adds the fields of struct v by their json names in
+// order. Fields of embedded structs are added unless the name is taken.
+func collectMsgPackFields(v reflect.Value, fields map[string]reflect.Value, names *[]string) {
+	t := v.Type()
+	var embedded []reflect.Value
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		options := strings.Split(tag, ",")
+		name := options[0]
+		fv := v.Field(i)
+		if field.Anonymous && name == "" {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() || fv.Type().Elem().Kind() != reflect.Struct {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			if fv.Kind() == reflect.Struct {
+				embedded = append(embedded, fv)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if contains(options[1:], "omitempty") && isEmptyMsgPackValue(fv) {
+			continue
+		}
+		if _, ok := fields[name]; !ok {
+			*names = append(*names, name)
+		}
+		fields[name] = fv
+	}
+	for _, fv := range embedded {
+		inner := make(map[string]reflect.Value)
+		var innerNames []string
+		collectMsgPackFields(fv, inner, &innerNames)
+		for _, name := range innerNames {
+			if _, ok := fields[name]; !ok {
+				fields[name] = inner[name]
+				*names = append(*names, name)
+			}
+		}
+	}
+}
+
+// isEmptyMsgPackValue is what encoding/json omits for omitempty.
+func isEmptyMsgPackValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return v.IsZero()
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+func writeMsgPack(b *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		b.WriteByte(0xc0)
+	case bool:
+		if v {
+			b.WriteByte(0xc3)
+		} else {
+			b.WriteByte(0xc2)
+		}
+	case json.Number:
+		if n, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			writeMsgPackInt(b, n)
+			return nil
+		}
+		if n, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			b.WriteByte(0xcf)
+			binary.Write(b, binary.BigEndian, n)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		b.WriteByte(0xcb)
+		binary.Write(b, binary.BigEndian, math.Float64bits(f))
+	case string:
+		writeMsgPackHeader(b, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		b.WriteString(v)
+	case []interface{}:
+		writeMsgPackHeader(b, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := writeMsgPack(b, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeMsgPackHeader(b, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, key := range keys {
+			writeMsgPack(b, key)
+			if err := writeMsgPack(b, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+	return nil
+}
+
+func writeMsgPackInt(b *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 127:
+		b.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		b.WriteByte(byte(int8(n)))
+	case n >= math.MinInt8 && n <= math.MaxInt8:
+		b.WriteByte(0xd0)
+		b.WriteByte(byte(int8(n)))
+	case n >= math.MinInt16 && n <= math.MaxInt16:
+		b.WriteByte(0xd1)
+		binary.Write(b, binary.BigEndian, int16(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		b.WriteByte(0xd2)
+		binary.Write(b, binary.BigEndian, int32(n))
+	default:
+		b.WriteByte(0xd3)
+		binary.Write(b, binary.BigEndian, n)
+	}
+}
+
+// writeMsgPackHeader writes the fix, 8, 16 or 32 bit header of a string,
+// array or map of size n. A zero code8 means there is no 8 bit form.
+func writeMsgPackHeader(b *bytes.Buffer, n int, fix byte, fixMax int, code8, code16, code32 byte) {
+	switch {
+	case n <= fixMax:
+		b.WriteByte(fix | byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		b.WriteByte(code8)
+		b.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		b.WriteByte(code16)
+		binary.Write(b, binary.BigEndian, uint16(n))
+	default:
+		b.WriteByte(code32)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	}
+}
+
+var errMsgPackShort = errors.New("msgpack: unexpected end of data")
+
+// unmarshalMsgPack decodes MessagePack into v through encoding/json, so json
+// tags apply. bin is decoded into []byte fields.
+func unmarshalMsgPack(data []byte, v interface{}) error {
+	reader := &msgPackReader{data: data}
+	tree, err := reader.read()
+	if err != nil {
+		return err
+	}
+	if reader.pos != len(data) {
+		return fmt.Errorf("msgpack: unexpected data at offset %d", reader.pos)
+	}
+	encoded, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, v)
+}
+
+type msgPackReader struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+func (r *msgPackReader) next(n int) ([]byte, error) {
+	if n < 0 || r.pos+n > len(r.data) {
+		return nil, errMsgPackShort
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *msgPackReader) uint(n int) (uint64, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (r *msgPackReader) read() (interface{}, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return r.str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return r.array(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return r.mapping(int(c & 0x0f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return r.uint(1 << (c - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := r.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		shift := uint(64 - size*8)
+		return int64(v<<shift) >> shift, nil
+	case 0xca:
+		v, err := r.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := r.uint(8)
+		return math.Float64frombits(v), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.str(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		return r.next(int(n))
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.array(int(n))
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.mapping(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: unsupported type 0x%x at offset %d", c, r.pos-1)
+}
+
+func (r *msgPackReader) str(n int) (interface{}, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (r *msgPackReader) array(n int) (interface{}, error) {
+	if n > len(r.data)-r.pos {
+		return nil, errMsgPackShort
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > maxDecodeDepth {
+		return nil, fmt.Errorf("msgpack: %v", errDecodeDepth)
+	}
+	list := make([]interface{}, n)
+	for i := range list {
+		item, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+		list[i] = item
+	}
+	return list, nil
+}
+
+func (r *msgPackReader) mapping(n int) (interface{}, error) {
+	if n > len(r.data)-r.pos {
+		return nil, errMsgPackShort
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > maxDecodeDepth {
+		return nil, fmt.Errorf("msgpack: %v", errDecodeDepth)
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		key, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+		value, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+	return m, nil
+}
@@ -0,0 +1,360 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIDoc is an OpenAPI 3.0 document, reduced to what the router can
+// describe.
+type OpenAPIDoc struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       OpenAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components OpenAPIComponents                       `json:"components"`
+
+	// schemaTypes keeps the type of every schema name to tell clashes apart
+	schemaTypes map[string]reflect.Type
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	OperationID string                      `json:"operationId,omitempty"`
+	Summary     string                      `json:"summary,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// OpenAPI describes every registered route. The in and out structs of
+// func(in, out) handlers become the request and response schemas.
+func (r *Router) OpenAPI(info OpenAPIInfo) *OpenAPIDoc {
+	doc := &OpenAPIDoc{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+		Components: OpenAPIComponents{
+			Schemas: make(map[string]*Schema),
+		},
+		schemaTypes: map[string]reflect.Type{"ErrorResponse": reflect.TypeOf(ErrorResponse{})},
+	}
+	doc.Components.Schemas["ErrorResponse"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":  {Type: "integer"},
+			"message": {Type: "string"},
+			"data":    {},
+		},
+		Required: []string{"status"},
+	}
+
+	for _, route := range r.rootRouter().routes {
+		if route.method == http.MethodConnect || route.method == http.MethodTrace {
+			continue
+		}
+		path, params := openAPIPath(route.path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = make(map[string]*OpenAPIOperation)
+		}
+		doc.Paths[path][strings.ToLower(route.method)] = doc.operation(route, params)
+	}
+	return doc
+}
+
+func (doc *OpenAPIDoc) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (doc *OpenAPIDoc) YAML() ([]byte, error) {
+	return marshalYAML(doc)
+}
+
+// ServeOpenAPI registers a GET route answering the document, as YAML when the
+// path ends with .yaml or .yml and as JSON otherwise.
+func (r *Router) ServeOpenAPI(path string, info OpenAPIInfo) *Route {
+	yaml := strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml")
+	root := r.rootRouter()
+	return r.GET(path, func(c *Context) {
+		doc := root.OpenAPI(info)
+		var data []byte
+		var err error
+		if yaml {
+			data, err = doc.YAML()
+			c.contentType = "application/yaml;charset=UTF-8"
+		} else {
+			data, err = doc.JSON()
+			c.contentType = "application/json;charset=UTF-8"
+		}
+		if err != nil {
+			panic(err)
+		}
+		c.responseData = data
+		c.hasResponse = true
+	})
+}
+
+// openAPIPath turns /users/:id into /users/{id} and returns the param names.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if segment != "" && (segment[0] == ':' || segment[0] == '*') {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+func (doc *OpenAPIDoc) operation(route *route, params []string) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		OperationID: route.name,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+	if len(route.own) > 0 {
+		endpoint := route.own[len(route.own)-1]
+		op.Summary = nameOfHandler(endpoint)
+	}
+	for _, name := range params {
+		op.Parameters = append(op.Parameters, &OpenAPIParameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	var data *Schema
+	in, out, ok := route.io()
+	if ok {
+		for _, field := range planOf(in).fields {
+			if field.source == sourceForm || (field.source == sourcePath && contains(params, field.name)) {
+				continue
+			}
+			op.Parameters = append(op.Parameters, &OpenAPIParameter{
+				Name:     field.name,
+				In:       sourceTags[field.source],
+				Required: field.source == sourcePath,
+				Schema:   doc.schema(in.FieldByIndex(field.index).Type),
+			})
+		}
+		if route.method != http.MethodGet && route.method != http.MethodHead && route.method != http.MethodDelete {
+			op.RequestBody = &OpenAPIRequestBody{
+				Content: map[string]*OpenAPIMediaType{
+					"application/json": {Schema: doc.schema(in)},
+				},
+			}
+		}
+		data = doc.schema(out)
+	} else {
+		data = &Schema{}
+	}
+
+	op.Responses["200"] = &OpenAPIResponse{
+		Description: "OK",
+		Content: map[string]*OpenAPIMediaType{
+			"application/json": {Schema: &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"status": {Type: "integer"},
+					"data":   data,
+				},
+				Required: []string{"status"},
+			}},
+		},
+	}
+	op.Responses["default"] = &OpenAPIResponse{
+		Description: "Error",
+		Content: map[string]*OpenAPIMediaType{
+			"application/json": {Schema: &Schema{Ref: "#/components/schemas/ErrorResponse"}},
+		},
+	}
+	return op
+}
+
+// io returns the in and out struct types of the route's endpoint handler.
+func (route *route) io() (in, out reflect.Type, ok bool) {
+	if len(route.own) == 0 {
+		return nil, nil, false
+	}
+	endpoint := route.own[len(route.own)-1]
+	if typed, ok := endpoint.(typedHandler); ok {
+		in, out := typed.io()
+		return in, out, in != nil
+	}
+	handlerType := reflect.TypeOf(endpoint)
+	if handlerType == nil || handlerType.Kind() != reflect.Func || handlerType.NumIn() < 2 {
+		return nil, nil, false
+	}
+	if handlerType.In(0) == contextType {
+		return nil, nil, false
+	}
+	return handlerType.In(0).Elem(), handlerType.In(1).Elem(), true
+}
+
+// schema returns the schema of t, named structs are added to the components
+// and referenced.
+func (doc *OpenAPIDoc) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return &Schema{Type: "string", Format: "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: doc.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: doc.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return doc.structSchema(t)
+		}
+		name := doc.schemaName(t)
+		if _, exist := doc.Components.Schemas[name]; !exist {
+			// reserve the name first so recursive types terminate
+			doc.Components.Schemas[name] = &Schema{Type: "object"}
+			doc.Components.Schemas[name] = doc.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+var schemaNameReplacer = strings.NewReplacer("[", "_", "]", "", "*", "", " ", "", ",", "_", "/", "_")
+
+// schemaName names t after its type. Another type of the same name gets the
+// package path as prefix, and a number if that clashes too.
+func (doc *OpenAPIDoc) schemaName(t reflect.Type) string {
+	name := t.String()
+	if i := strings.LastIndex(name, "."); i >= 0 && strings.Index(name, "[") < 0 {
+		name = name[i+1:]
+	}
+	name = schemaNameReplacer.Replace(name)
+	if other, exist := doc.schemaTypes[name]; exist && other != t {
+		name = schemaNameReplacer.Replace(t.PkgPath()) + "." + name
+		for i, base := 2, name; ; i++ {
+			if other, exist := doc.schemaTypes[name]; !exist || other == t {
+				break
+			}
+			name = base + strconv.Itoa(i)
+		}
+	}
+	doc.schemaTypes[name] = t
+	return name
+}
+
+func (doc *OpenAPIDoc) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	doc.addFields(schema, t)
+	return schema
+}
+
+func (doc *OpenAPIDoc) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				doc.addFields(schema, embedded)
+				continue
+			}
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		property := doc.schema(field.Type)
+		if field.Type.Kind() == reflect.Ptr && property.Ref == "" {
+			property.Nullable = true
+		}
+		schema.Properties[name] = property
+		for _, rule := range strings.Split(field.Tag.Get("valid"), ",") {
+			if strings.TrimSpace(rule) == "required" {
+				schema.Required = append(schema.Required, name)
+			}
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type openAPIUser struct {
+	ID      int64          `json:"id"`
+	Name    string         `json:"name"`
+	Tags    []string       `json:"tags,omitempty"`
+	Friends []*openAPIUser `json:"friends"`
+	secret  string
+}
+
+func showOpenAPIUser(in *In, out *openAPIUser) *ErrorResponse {
+	return nil
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	router := New()
+	router.POST("/hello", HelloPost).Name("hello")
+	router.GET("/users/:id", showOpenAPIUser)
+	router.GET("/ping", func(c *Context) {})
+	router.ServeOpenAPI("/openapi.json", OpenAPIInfo{Title: "test", Version: "1.0"})
+	router.ServeOpenAPI("/openapi.yaml", OpenAPIInfo{Title: "test", Version: "1.0"})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	doc := &OpenAPIDoc{}
+	if err := json.Unmarshal(w.Body.Bytes(), doc); err != nil {
+		t.Fatal(err, w.Body.String())
+	}
+
+	hello := doc.Paths["/hello"]["post"]
+	if hello == nil || hello.OperationID != "hello" {
+		t.Fatalf("missing POST /hello operation %+v", doc.Paths)
+	}
+	in := hello.RequestBody.Content["application/json"].Schema
+	if in.Ref != "#/components/schemas/In" || doc.Components.Schemas["In"].Properties["birthday"].Type != "string" {
+		t.Errorf("unexpected request schema %+v", in)
+	}
+	envelope := hello.Responses["200"].Content["application/json"].Schema
+	if envelope.Properties["status"].Type != "integer" || envelope.Properties["data"].Ref != "#/components/schemas/Out" {
+		t.Errorf("unexpected response envelope %+v", envelope)
+	}
+
+	user := doc.Paths["/users/{id}"]["get"]
+	if user == nil || len(user.Parameters) != 1 || user.Parameters[0].Name != "id" || user.RequestBody != nil {
+		t.Fatalf("unexpected GET /users/{id} operation %+v", user)
+	}
+	schema := doc.Components.Schemas["openAPIUser"]
+	if schema.Properties["id"].Format != "int64" ||
+		schema.Properties["friends"].Items.Ref != "#/components/schemas/openAPIUser" ||
+		schema.Properties["secret"] != nil {
+		t.Errorf("unexpected user schema %+v", schema)
+	}
+	if doc.Paths["/ping"]["get"] == nil {
+		t.Error("missing GET /ping operation")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.yaml", nil))
+	yaml := w.Body.String()
+	for _, line := range []string{
+		`openapi: "3.0.3"` + "\n",
+		"  /users/{id}:\n",
+		"        - in: path\n",
+		`        "200":` + "\n",
+	} {
+		if !strings.Contains(yaml, line) {
+			t.Errorf("expected %q in yaml:\n%s", line, yaml)
+		}
+	}
+}
+
+type Cookie struct {
+	Flavor string `json:"flavor"`
+}
+
+type openAPICookies struct {
+	Baked Cookie      `json:"baked"`
+	Sent  http.Cookie `json:"sent"`
+	Again *Cookie     `json:"again"`
+}
+
+func TestRouter_OpenAPISchemaNames(t *testing.T) {
+	router := New()
+	router.POST("/cookies", func(in *openAPICookies, out *ErrorResponse) {})
+	doc := router.OpenAPI(OpenAPIInfo{Title: "test", Version: "1.0"})
+
+	schema := doc.Components.Schemas["openAPICookies"]
+	baked, sent := schema.Properties["baked"].Ref, schema.Properties["sent"].Ref
+	if baked != "#/components/schemas/Cookie" || sent != "#/components/schemas/net_http.Cookie" ||
+		schema.Properties["again"].Ref != baked {
+		t.Errorf("unexpected schema names %s %s %s", baked, sent, schema.Properties["again"].Ref)
+	}
+	if doc.Components.Schemas["Cookie"].Properties["flavor"] == nil ||
+		doc.Components.Schemas["net_http.Cookie"].Properties["Domain"] == nil {
+		t.Errorf("unexpected cookie schemas %+v", doc.Components.Schemas)
+	}
+	envelope := doc.Paths["/cookies"]["post"].Responses["200"].Content["application/json"].Schema
+	if envelope.Properties["data"].Ref != "#/components/schemas/ErrorResponse" || len(doc.Components.Schemas) != 4 {
+		t.Errorf("unexpected schemas %+v", doc.Components.Schemas)
+	}
+}
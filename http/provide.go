@@ -0,0 +1,81 @@
+package http
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// provider builds the value injected into handler params of one type.
+type provider func(c *Context) (reflect.Value, error)
+
+// Provide registers values injected into the params of func(in, out, ...)
+// handlers by their type. A provider is func(*Context) T, func(*Context)
+// (T, error) or a value of T injected as is:
+//
+//	router.Provide(db, func(c *Context) (*User, error) {
+//		return findUser(c.Request.Header.Get("Authorization"))
+//	})
+//	router.GET("/orders", func(in *In, out *Out, user *User, db *sql.DB) error {...})
+//
+// A provider error is answered like a handler error. Register providers
+// before the routes using them, params without provider fail at
+// registration.
+func (r *Router) Provide(providers ...interface{}) *Router {
+	root := r.rootRouter()
+	if root.providers == nil {
+		root.providers = make(map[reflect.Type]provider)
+	}
+	for _, p := range providers {
+		t, fn := newProvider(p)
+		if t == contextType {
+			panic("http: *Context is always injected and cannot be provided")
+		}
+		root.providers[t] = fn
+	}
+	return r
+}
+
+func newProvider(p interface{}) (reflect.Type, provider) {
+	v := reflect.ValueOf(p)
+	if !v.IsValid() {
+		panic("http: provider must not be nil")
+	}
+	t := v.Type()
+	if t.Kind() != reflect.Func || t.NumIn() != 1 || t.In(0) != contextType ||
+		t.NumOut() == 0 || t.NumOut() > 2 || (t.NumOut() == 2 && t.Out(1) != errorType) {
+		return t, func(c *Context) (reflect.Value, error) {
+			return v, nil
+		}
+	}
+	withError := t.NumOut() == 2
+	return t.Out(0), func(c *Context) (reflect.Value, error) {
+		results := v.Call([]reflect.Value{reflect.ValueOf(c)})
+		if withError {
+			if err, _ := results[1].Interface().(error); !isNilError(err) {
+				return reflect.Value{}, err
+			}
+		}
+		return results[0], nil
+	}
+}
+
+// injectorsOf returns a provider for every param of handlerType after in and
+// out, *Context is injected without one.
+func injectorsOf(handlerType reflect.Type, providers map[reflect.Type]provider) ([]provider, error) {
+	injectors := make([]provider, 0, handlerType.NumIn()-2)
+	for i := 2; i < handlerType.NumIn(); i++ {
+		paramType := handlerType.In(i)
+		if paramType == contextType {
+			injectors = append(injectors, func(c *Context) (reflect.Value, error) {
+				return reflect.ValueOf(c), nil
+			})
+			continue
+		}
+		p, ok := providers[paramType]
+		if !ok {
+			return nil, fmt.Errorf("handler param #%d: no provider for %v", i+1, paramType)
+		}
+		injectors = append(injectors, p)
+	}
+	return injectors, nil
+}
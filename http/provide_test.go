@@ -0,0 +1,95 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type provideUser struct {
+	Name string
+}
+
+type provideDB struct {
+	name string
+}
+
+type provideIn struct {
+	ID int64 `path:"id"`
+}
+
+type provideOut struct {
+	User string `json:"user"`
+	DB   string `json:"db"`
+	ID   int64  `json:"id"`
+}
+
+func TestRouter_Provide(t *testing.T) {
+	router := New()
+	router.Use(func(c *Context) {
+		if name := c.Request.Header.Get("X-User"); name != "" {
+			c.SetMetaData("user", &provideUser{Name: name})
+		}
+		c.Next()
+	})
+	router.Provide(&provideDB{name: "main"}, func(c *Context) (*provideUser, error) {
+		user, ok := GetAs[*provideUser](c, "user")
+		if !ok {
+			return nil, &ErrorResponse{Status: http.StatusUnauthorized, Message: "login required"}
+		}
+		return user, nil
+	})
+	router.GET("/orders/:id", func(in *provideIn, out *provideOut, user *provideUser, c *Context, db *provideDB) {
+		out.User, out.DB, out.ID = user.Name, db.name, in.ID
+		if c.Param("id") != "7" {
+			t.Errorf("unexpected context param %s", c.Param("id"))
+		}
+	})
+
+	tests := []struct {
+		user   string
+		status int
+		body   string
+	}{
+		{"Lywane", http.StatusOK, `{"data":{"user":"Lywane","db":"main","id":7},"status":0}`},
+		{"", http.StatusUnauthorized, `{"status":401,"message":"login required"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+		req.Header.Set("X-User", test.user)
+		router.ServeHTTP(w, req)
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("%q: unexpected %d %s", test.user, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_ProvideMissing(t *testing.T) {
+	defer func() {
+		err := recover()
+		if err == nil || !strings.Contains(err.(string), "no provider for *http.provideDB") {
+			t.Errorf("unexpected panic %v", err)
+		}
+	}()
+	router := New()
+	router.GET("/orders", func(in *provideIn, out *provideOut, db *provideDB) {})
+}
+
+func TestMustGetAs(t *testing.T) {
+	c := newContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder(), nil)
+	c.SetMetaData("tenant", "acme")
+	if MustGetAs[string](c, "tenant") != "acme" {
+		t.Error("unexpected tenant")
+	}
+	if _, ok := GetAs[int](c, "tenant"); ok {
+		t.Error("expected a string not to be an int")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for missing meta data")
+		}
+	}()
+	MustGetAs[*provideUser](c, "user")
+}
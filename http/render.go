@@ -0,0 +1,41 @@
+package http
+
+import "encoding/xml"
+
+// Renderer shapes the bodies Json and the error paths write. Success wraps
+// the data of Json, Error the errors of Fail, DieWithHttpStatus and the
+// NoRoute/NoMethod defaults, status is the http status they are answered with.
+type Renderer interface {
+	Success(c *Context, data interface{}) interface{}
+	Error(c *Context, status int, err *ErrorResponse) interface{}
+}
+
+type envelope struct {
+	XMLName xml.Name    `json:"-" xml:"response"`
+	Data    interface{} `json:"data" xml:"data"`
+	Status  int         `json:"status" xml:"status"`
+}
+
+// DefaultRenderer writes {"data":...,"status":0} and the ErrorResponse as is.
+type DefaultRenderer struct{}
+
+func (DefaultRenderer) Success(c *Context, data interface{}) interface{} {
+	return &envelope{Data: data}
+}
+
+func (DefaultRenderer) Error(c *Context, status int, err *ErrorResponse) interface{} {
+	return err
+}
+
+// SetRenderer replaces the envelope of every response of the router.
+func (r *Router) SetRenderer(renderer Renderer) *Router {
+	r.rootRouter().renderer = renderer
+	return r
+}
+
+func (this *Context) renderer() Renderer {
+	if this.router != nil && this.router.renderer != nil {
+		return this.router.renderer
+	}
+	return DefaultRenderer{}
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type traceRenderer struct{}
+
+func (traceRenderer) Success(c *Context, data interface{}) interface{} {
+	return map[string]interface{}{
+		"code":     0,
+		"msg":      "ok",
+		"data":     data,
+		"trace_id": c.GetHeader("Kelp-Traceid"),
+	}
+}
+
+func (traceRenderer) Error(c *Context, status int, err *ErrorResponse) interface{} {
+	return map[string]interface{}{
+		"code":     err.Status,
+		"msg":      err.Message,
+		"trace_id": c.GetHeader("Kelp-Traceid"),
+	}
+}
+
+func TestRouter_SetRenderer(t *testing.T) {
+	router := New()
+	router.SetRenderer(traceRenderer{})
+	router.GET("/ok", func(c *Context) {
+		c.Json("hi")
+	})
+	router.GET("/raw", func(c *Context) {
+		c.JsonRaw([]int{1, 2})
+	})
+	router.GET("/fail", func(c *Context) error {
+		return errors.New("boom")
+	})
+	router.GET("/die", func(c *Context) {
+		c.DieWithHttpStatus(http.StatusUnauthorized)
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/ok", http.StatusOK, `{"code":0,"data":"hi","msg":"ok","trace_id":"t1"}`},
+		{"/raw", http.StatusOK, `[1,2]`},
+		{"/fail", http.StatusInternalServerError, `{"code":500,"msg":"boom","trace_id":"t1"}`},
+		{"/die", http.StatusUnauthorized, `{"code":401,"msg":"Unauthorized","trace_id":"t1"}`},
+		{"/missing", http.StatusNotFound, `{"code":404,"msg":"Not Found","trace_id":"t1"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, test.path, nil)
+		req.Header.Set("Kelp-Traceid", "t1")
+		router.ServeHTTP(w, req)
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("%s: expected %d %s, got %d %s", test.path, test.status, test.body, w.Code, w.Body.String())
+		}
+	}
+}
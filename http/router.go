@@ -2,8 +2,10 @@ package http
 
 import (
 	"net/http"
+	"fmt"
+	"sort"
+	"strings"
 	"reflect"
-	"encoding/json"
 )
 
 const (
@@ -15,24 +17,24 @@ type Handler interface{}
 
 type HandlerChain []Handler
 
-type ErrorResponse struct {
-	Status  int         `json:"status"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-}
-
-func ReturnError(status int, err error) *ErrorResponse {
-	return &ErrorResponse{
-		Status:  status,
-		Message: err.Error(),
-	}
-}
-
 type Router struct {
 	root       *Router
-	trees      map[string]map[string]HandlerChain
+	parent     *Router
+	routes     []*route
+	trees      map[string]*node
 	basePath   string
 	middleWare HandlerChain
+	noRoute    *route
+	noMethod   *route
+	options    *route
+	names      map[string]*route
+	debug      bool
+	renderer   Renderer
+
+	maxBodySize           int64
+	disallowUnknownFields bool
+	upload                *UploadConfig
+	providers             map[reflect.Type]provider
 }
 
 type RouterGroup struct {
@@ -40,9 +42,44 @@ type RouterGroup struct {
 }
 
 func New() *Router {
-	return &Router{
-		trees: make(map[string]map[string]HandlerChain),
+	router := &Router{
+		trees: make(map[string]*node),
 	}
+	router.NoRoute(notFoundHandler)
+	router.NoMethod(methodNotAllowedHandler)
+	// automatic OPTIONS answers run after the root middleware, so CORSHandler
+	// sees them
+	router.options = &route{group: router, own: HandlerChain{optionsHandler}}
+	router.build(router.options)
+	return router
+}
+
+func notFoundHandler(c *Context) {
+	c.DieWithHttpStatus(http.StatusNotFound)
+}
+
+func methodNotAllowedHandler(c *Context) {
+	c.DieWithHttpStatus(http.StatusMethodNotAllowed)
+}
+
+func optionsHandler(c *Context) {
+	c.NoContent()
+}
+
+// NoRoute sets the handlers for requests matching no route. They run after
+// the root middleware.
+func (r *Router) NoRoute(handlers ...Handler) {
+	root := r.rootRouter()
+	root.noRoute = &route{group: root, own: handlers}
+	root.build(root.noRoute)
+}
+
+// NoMethod sets the handlers for requests whose path only exists under other
+// methods. They run after the root middleware, the Allow header is already set.
+func (r *Router) NoMethod(handlers ...Handler) {
+	root := r.rootRouter()
+	root.noMethod = &route{group: root, own: handlers}
+	root.build(root.noMethod)
 }
 
 // func handler(c *Context) {}
@@ -51,139 +88,284 @@ func New() *Router {
 // func handler(in *struct, out *struct, c *Context) *ErrorResponse {}
 
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	path := req.URL.Path
-	method := req.Method
-	pathHandlers, exist := r.trees[method]
-	if !exist {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		return
+	c := newContext(req, w, nil)
+	r.dispatch(c)
+	c.release()
+}
+
+func (r *Router) dispatch(c *Context) {
+	path := c.Request.URL.Path
+	method := c.Request.Method
+	value := r.lookup(method, path, &c.params)
+	if value == nil && method == http.MethodHead {
+		// HEAD is served from GET routes, response() drops the body
+		c.params = c.params[:0]
+		value = r.lookup(http.MethodGet, path, &c.params)
 	}
-	handlers, exist := pathHandlers[path]
-	if !exist {
-		w.WriteHeader(http.StatusNotFound)
+	if value == nil {
+		c.params = c.params[:0]
+		allow := r.allowed(path)
+		if len(allow) == 0 {
+			r.serve(c, r.noRoute)
+			return
+		}
+		c.SetHeader("Allow", strings.Join(allow, ", "))
+		if method == http.MethodOptions {
+			r.serve(c, r.options)
+			return
+		}
+		r.serve(c, r.noMethod)
 		return
 	}
-	c := newContext(req, w, handlers)
+	r.serve(c, value.route)
+}
+
+func (r *Router) serve(c *Context, route *route) {
+	c.router = r
+	c.route = route
+	c.invokers = route.invokers
 	c.handle()
 }
 
+func (r *Router) lookup(method, path string, params *Params) *node {
+	root := r.trees[method]
+	if root == nil {
+		return nil
+	}
+	return root.getValue(path, params)
+}
+
+// allowed returns the methods registered for path, including the HEAD and
+// OPTIONS methods the router answers automatically.
+func (r *Router) allowed(path string) []string {
+	var allow []string
+	var params Params
+	for method, root := range r.trees {
+		params = params[:0]
+		if root.getValue(path, &params) != nil {
+			allow = append(allow, method)
+		}
+	}
+	if len(allow) == 0 {
+		return nil
+	}
+	has := func(method string) bool {
+		for _, m := range allow {
+			if m == method {
+				return true
+			}
+		}
+		return false
+	}
+	if has(http.MethodGet) && !has(http.MethodHead) {
+		allow = append(allow, http.MethodHead)
+	}
+	if !has(http.MethodOptions) {
+		allow = append(allow, http.MethodOptions)
+	}
+	sort.Strings(allow)
+	return allow
+}
+
 func (c *Context) handle() {
-	defer c.response()
+	defer c.finish()
 	c.processHandler()
 }
 
+// finish sends the response and runs the OnAfterWrite hooks.
+func (c *Context) finish() {
+	c.response()
+	for _, fn := range c.afterWrite {
+		fn(c)
+	}
+}
+
 func (c *Context) processHandler() {
-	handlerFunc := c.handlerChain[c.handlerIndex]
-	handlerType := reflect.TypeOf(handlerFunc)
-	// handler 类型必须是 func
-	if handlerType.Kind() != reflect.Func {
-		panic("handler type must be func " + handlerType.Name())
-	}
-	handler := reflect.ValueOf(handlerFunc)
-
-	var res interface{}
-	var args []reflect.Value
-	var param, response reflect.Value
-	var hasResponse bool
-	numIn := handlerType.NumIn()
-	if numIn == 1 {
-		paramType := handlerType.In(0)
-		if paramType.Kind() == reflect.TypeOf(&Context{}).Kind() {
-			args = append(args, reflect.ValueOf(c))
-		} else {
-			panic("When handler has one param, it must be ptr to Context" + handlerType.Name())
-		}
+	c.invokers[c.handlerIndex](c)
+}
+
+// Group returns a child router for path. The group inherits the middleware of
+// its parents, handlers are appended as its own middleware. A handler of type
+// func(*Router) is called with the new group instead, so routes can be
+// declared in a block:
+//
+//	router.Group("/admin", AuthHandler, func(g *Router) {
+//		g.GET("/users", listUsers)
+//	})
+func (r *Router) Group(path string, handlers ...Handler) *Router {
+	router := &Router{
+		basePath: r.basePath + path,
+		parent:   r,
+		root:     r.rootRouter(),
 	}
-	if numIn > 1 {
-		hasResponse = true
-		paramType := handlerType.In(0)
-		if paramType.Kind() == reflect.Ptr {
-			param = reflect.New(paramType.Elem())
-			json.Unmarshal(c.Body(), param.Interface())
-			args = append(args, reflect.ValueOf(param.Interface()))
-		} else {
-			panic("When handler more than one param, first must be ptr to struct" + handlerType.Name())
-		}
-		responseType := handlerType.In(1)
-		if responseType.Kind() == reflect.Ptr {
-			response = reflect.New(responseType.Elem())
-			args = append(args, reflect.ValueOf(response.Interface()))
+
+	var blocks []func(*Router)
+	var middleWare HandlerChain
+	for _, handler := range handlers {
+		if block, ok := handler.(func(*Router)); ok {
+			blocks = append(blocks, block)
 		} else {
-			panic("When handler more than one param, second must be ptr to struct" + handlerType.Name())
+			middleWare = append(middleWare, handler)
 		}
 	}
-	if numIn == 3 {
-		paramType := handlerType.In(2)
-		if paramType.Kind() == reflect.TypeOf(&Context{}).Kind() {
-			args = append(args, reflect.ValueOf(c))
-		} else {
-			panic("When handler has three param, third must be ptr to Context" + handlerType.Name())
-		}
+	if len(middleWare) > 0 {
+		router.Use(middleWare...)
 	}
-	errs := handler.Call(args)
-	if len(errs) > 0 && !errs[0].IsNil() {
-		res = errs[0].Interface()
-		c.Json(res)
-	} else if hasResponse {
-		res = response.Interface()
-		c.Json(res)
-	} else if !c.hasResponse {
-		c.Json(map[string]interface{}{})
+	for _, block := range blocks {
+		block(router)
 	}
+	return router
+}
 
-	return
+// Use appends middleware to the router. It applies to every route of the
+// router and its groups, including routes registered before the call.
+func (r *Router) Use(handlers ...Handler) *Router {
+	for _, handler := range handlers {
+		if _, err := compileHandler(handler, r.rootRouter().providers); err != nil {
+			panic(fmt.Sprintf("http: invalid middleware %T: %v", handler, err))
+		}
+	}
+	r.middleWare = append(r.middleWare, handlers...)
+	r.rootRouter().rebuild()
+	return r
 }
 
-func (r *Router) Group(path string) *Router {
-	router := New()
-	router.basePath = r.basePath + path
-	copy(router.middleWare, r.middleWare)
-	if r.root == nil {
-		router.root = r
-	} else {
-		router.root = r.root
+func (r *Router) rebuild() {
+	for _, route := range r.routes {
+		r.build(route)
 	}
-	return router
+	if r.noRoute != nil {
+		r.build(r.noRoute)
+	}
+	if r.noMethod != nil {
+		r.build(r.noMethod)
+	}
+	if r.options != nil {
+		r.build(r.options)
+	}
+}
+
+// SetMaxBodySize limits request bodies to n bytes, larger bodies are answered
+// with 413. Route.MaxBodySize overrides it, 0 means no limit.
+func (r *Router) SetMaxBodySize(n int64) *Router {
+	r.rootRouter().maxBodySize = n
+	return r
 }
 
-func (r *Router) Use(handler Handler) *Router {
-	if r.middleWare == nil {
-		r.middleWare = []Handler{}
+// DisallowUnknownFields rejects JSON bodies with fields the in struct does not
+// have on every route.
+func (r *Router) DisallowUnknownFields(disallow bool) *Router {
+	r.rootRouter().disallowUnknownFields = disallow
+	return r
+}
+
+func (r *Router) rootRouter() *Router {
+	if r.root != nil {
+		return r.root
 	}
-	r.middleWare = append(r.middleWare, handler)
 	return r
 }
 
-func (r *Router) GET(path string, handler ...Handler) {
-	r.handle(http.MethodGet, path, handler)
+// middleWareChain returns the middleware of all parents followed by r's own.
+func (r *Router) middleWareChain() HandlerChain {
+	if r.parent == nil {
+		return r.middleWare
+	}
+	parent := r.parent.middleWareChain()
+	chain := make(HandlerChain, 0, len(parent)+len(r.middleWare))
+	chain = append(chain, parent...)
+	return append(chain, r.middleWare...)
 }
 
-func (r *Router) POST(path string, handler ...Handler) {
-	r.handle(http.MethodPost, path, handler)
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+	http.MethodConnect, http.MethodTrace,
 }
 
-func (r *Router) handle(method, path string, handlerChain HandlerChain) {
-	path = r.basePath + path
-	var router *Router
+func (r *Router) GET(path string, handler ...Handler) *Route {
+	return r.handle(http.MethodGet, path, handler)
+}
 
-	if r.root != nil {
-		router = r.root
-	} else {
-		router = r
+func (r *Router) POST(path string, handler ...Handler) *Route {
+	return r.handle(http.MethodPost, path, handler)
+}
+
+func (r *Router) PUT(path string, handler ...Handler) *Route {
+	return r.handle(http.MethodPut, path, handler)
+}
+
+func (r *Router) PATCH(path string, handler ...Handler) *Route {
+	return r.handle(http.MethodPatch, path, handler)
+}
+
+func (r *Router) DELETE(path string, handler ...Handler) *Route {
+	return r.handle(http.MethodDelete, path, handler)
+}
+
+// HEAD overrides the HEAD response the router derives from GET routes.
+func (r *Router) HEAD(path string, handler ...Handler) *Route {
+	return r.handle(http.MethodHead, path, handler)
+}
+
+// OPTIONS overrides the automatic OPTIONS response.
+func (r *Router) OPTIONS(path string, handler ...Handler) *Route {
+	return r.handle(http.MethodOptions, path, handler)
+}
+
+// Any registers the handlers for every standard method.
+func (r *Router) Any(path string, handler ...Handler) *Route {
+	route := &Route{router: r.rootRouter()}
+	for _, method := range anyMethods {
+		route.routes = append(route.routes, r.handle(method, path, handler).routes...)
+	}
+	return route
+}
+
+func (r *Router) Handle(method, path string, handler ...Handler) *Route {
+	if method == "" {
+		panic("http method must not be empty for path '" + path + "'")
 	}
+	return r.handle(method, path, handler)
+}
+
+func (r *Router) handle(method, path string, handlerChain HandlerChain) *Route {
+	path = r.basePath + path
+	router := r.rootRouter()
 
 	if router.trees == nil {
-		router.trees = make(map[string]map[string]HandlerChain)
+		router.trees = make(map[string]*node)
 	}
 
 	if router.trees[method] == nil {
-		router.trees[method] = make(map[string]HandlerChain)
+		router.trees[method] = &node{}
 	}
 
-	if router.trees[method][path] == nil {
-		router.trees[method][path] = HandlerChain{}
+	registered := &route{
+		method: method,
+		path:   path,
+		group:  r,
+		own:    handlerChain,
 	}
+	router.build(registered)
+	router.trees[method].addRoute(registered)
+	router.routes = append(router.routes, registered)
+	return &Route{router: router, routes: []*route{registered}}
+}
 
-	router.trees[method][path] = append(router.trees[method][path], r.middleWare...)
-	router.trees[method][path] = append(router.trees[method][path], handlerChain...)
+// build resolves the full handler chain of route and compiles it.
+func (r *Router) build(route *route) {
+	middleWare := route.group.middleWareChain()
+	handlers := make(HandlerChain, 0, len(middleWare)+len(route.own))
+	handlers = append(handlers, middleWare...)
+	handlers = append(handlers, route.own...)
+	invokers, err := compileChain(handlers, r.providers)
+	if err != nil {
+		if route.path == "" {
+			panic(fmt.Sprintf("http: invalid fallback handler: %v", err))
+		}
+		panic(fmt.Sprintf("http: invalid handler for %s %s: %v", route.method, route.path, err))
+	}
+	route.handlers = handlers
+	route.invokers = invokers
 }
@@ -2,6 +2,9 @@ package http
 
 import (
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"fmt"
 	"time"
@@ -49,16 +52,32 @@ func HelloGet(c *Context) *ErrorResponse {
 	return nil
 }
 
+var (
+	helloServer     *httptest.Server
+	helloServerOnce sync.Once
+)
+
+// helloURL starts the hello server once, so the tests below may run alone
+// and repeatedly.
+func helloURL(path string) string {
+	helloServerOnce.Do(func() {
+		router := New()
+		router.POST("/hello", HelloPost)
+		router.POST("/hello2", HelloPost2)
+		router.GET("/hello", HelloGet)
+		helloServer = httptest.NewServer(router)
+	})
+	return helloServer.URL + path
+}
+
 func TestHttp(t *testing.T) {
-	router := New()
-	router.POST("/hello", HelloPost)
-	router.POST("/hello2", HelloPost2)
-	router.GET("/hello", HelloGet)
-	go http.ListenAndServe(":8080", router)
+	if _, err := Get(helloURL("/hello")); err != nil {
+		t.Fatal(err)
+	}
 }
 
 func TestRouter_POST(t *testing.T) {
-	url := "http://127.0.0.1:8080/hello"
+	url := helloURL("/hello")
 	data := `{"name":"Lywane","birthday":"1994-06-25"}`
 
 	response, err := Post(url, []byte(data))
@@ -69,7 +88,7 @@ func TestRouter_POST(t *testing.T) {
 }
 
 func TestRouter_POST2(t *testing.T) {
-	url := "http://127.0.0.1:8080/hello2?birthday=1994-06-25"
+	url := helloURL("/hello2?birthday=1994-06-25")
 	data := `{"name":"Lywane"}`
 
 	response, err := Post(url, []byte(data))
@@ -80,7 +99,7 @@ func TestRouter_POST2(t *testing.T) {
 }
 
 func TestRouter_GET(t *testing.T) {
-	url := "http://127.0.0.1:8080/hello?name=Lywane&birthday=1994-06-25"
+	url := helloURL("/hello?name=Lywane&birthday=1994-06-25")
 
 	response, err := Get(url)
 	if err != nil {
@@ -113,3 +132,210 @@ func validResult(t *testing.T, response []byte) {
 		t.Fatal("hanler err", res.Data.Text)
 	}
 }
+
+func TestRouter_Param(t *testing.T) {
+	router := New()
+	router.GET("/users/:id", func(c *Context) {
+		c.Json(map[string]interface{}{"id": c.Param("id")})
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/42", nil))
+	if w.Body.String() != `{"data":{"id":"42"},"status":0}` {
+		t.Fatal("unexpected response", w.Body.String())
+	}
+}
+
+func TestRouter_Methods(t *testing.T) {
+	router := New()
+	router.GET("/items/:id", func(c *Context) {
+		c.Json(c.Param("id"))
+	})
+	router.DELETE("/items/:id", func(c *Context) {})
+	router.Handle("PURGE", "/items/:id", func(c *Context) {})
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		allow  string
+		body   string
+	}{
+		{http.MethodGet, "/items/1", http.StatusOK, "", `{"data":"1","status":0}`},
+		{http.MethodHead, "/items/1", http.StatusOK, "", ""},
+		{http.MethodOptions, "/items/1", http.StatusNoContent, "DELETE, GET, HEAD, OPTIONS, PURGE", ""},
+		{http.MethodPost, "/items/1", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PURGE", `{"status":405,"message":"Method Not Allowed"}`},
+		{http.MethodPost, "/missing", http.StatusNotFound, "", `{"status":404,"message":"Not Found"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected status %d, got %d", test.method, test.path, test.status, w.Code)
+		}
+		if allow := w.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", test.method, test.path, test.allow, allow)
+		}
+		if w.Body.String() != test.body {
+			t.Errorf("%s %s: expected body %q, got %q", test.method, test.path, test.body, w.Body.String())
+		}
+	}
+}
+
+func TestRouter_InvalidHandler(t *testing.T) {
+	invalid := []Handler{
+		"not a func",
+		func(name string) {},
+		func(in In, out *Out) *ErrorResponse { return nil },
+		func(in *In, out *Out, name string) *ErrorResponse { return nil },
+		func(in *In, out *Out) string { return "" },
+	}
+	for _, handler := range invalid {
+		func() {
+			defer func() {
+				err := recover()
+				if err == nil {
+					t.Errorf("expected panic for %T", handler)
+					return
+				}
+				if !strings.Contains(fmt.Sprint(err), "POST /invalid") {
+					t.Errorf("expected route in error, got %v", err)
+				}
+			}()
+			New().POST("/invalid", handler)
+		}()
+	}
+}
+
+func TestRouter_Group(t *testing.T) {
+	var trace []string
+	mark := func(name string) Handler {
+		return func(c *Context) {
+			trace = append(trace, name)
+			c.Next()
+		}
+	}
+	auth := func(c *Context) {
+		if c.GetHeader("Authorization") != "secret" {
+			c.DieWithHttpStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	}
+	ok := func(c *Context) {
+		c.Json("ok")
+	}
+
+	router := New()
+	router.Use(mark("root"))
+	admin := router.Group("/admin", auth)
+	admin.GET("/early", ok)
+	admin.Group("/users", func(g *Router) {
+		g.GET("/:id", ok)
+	})
+	admin.Group("/settings", mark("settings"), func(g *Router) {
+		g.GET("", ok)
+		g.POST("/:key", ok)
+	})
+	admin.Use(mark("admin"))
+	router.GET("/public", ok)
+
+	for _, path := range []string{"/admin/early", "/admin/users/1", "/admin/settings"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected 401 without auth, got %d", path, w.Code)
+		}
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/settings/theme", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without auth, got %d", w.Code)
+	}
+
+	trace = nil
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/settings", nil)
+	req.Header.Set("Authorization", "secret")
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200 with auth, got %d", w.Code)
+	}
+	if strings.Join(trace, ",") != "root,admin,settings" {
+		t.Errorf("unexpected middleware order %v", trace)
+	}
+
+	trace = nil
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/public", nil))
+	if w.Code != http.StatusOK || strings.Join(trace, ",") != "root" {
+		t.Errorf("unexpected public response %d %v", w.Code, trace)
+	}
+}
+
+func TestRouter_NoRoute(t *testing.T) {
+	var logged []string
+	router := New()
+	router.Use(func(c *Context) {
+		c.Next()
+		logged = append(logged, c.Request.Method+" "+c.Request.URL.Path)
+	})
+	router.GET("/exists", func(c *Context) {})
+	router.NoRoute(func(c *Context) *ErrorResponse {
+		c.DieWithHttpStatus(http.StatusTeapot)
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusTeapot {
+		t.Errorf("expected custom NoRoute status, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/exists", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") == "" {
+		t.Errorf("expected default NoMethod response, got %d", w.Code)
+	}
+	if strings.Join(logged, ",") != "GET /missing,POST /exists" {
+		t.Errorf("expected fallbacks to run through middleware, got %v", logged)
+	}
+}
+
+func TestRouter_Routes(t *testing.T) {
+	router := New()
+	router.Use(LogHandler)
+	api := router.Group("/api", RecoveryHandler)
+	api.GET("/users/:id", HelloGet).Name("user.show")
+	api.GET("/files/*path", HelloGet).Name("file")
+
+	routes := router.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(routes))
+	}
+	route := routes[0]
+	if route.Method != http.MethodGet || route.Path != "/api/users/:id" || route.Name != "user.show" {
+		t.Errorf("unexpected route %+v", route)
+	}
+	if len(route.Handlers) != 1 || !strings.HasSuffix(route.Handlers[0], ".HelloGet") {
+		t.Errorf("unexpected handlers %v", route.Handlers)
+	}
+	if len(route.MiddleWare) != 2 ||
+		!strings.HasSuffix(route.MiddleWare[0], ".LogHandler") ||
+		!strings.HasSuffix(route.MiddleWare[1], ".RecoveryHandler") {
+		t.Errorf("unexpected middleware %v", route.MiddleWare)
+	}
+
+	url, err := router.URL("user.show", map[string]string{"id": "a b", "tab": "posts"})
+	if err != nil || url != "/api/users/a%20b?tab=posts" {
+		t.Errorf("unexpected url %q %v", url, err)
+	}
+	url, err = router.URL("file", map[string]string{"path": "docs/read me.txt"})
+	if err != nil || url != "/api/files/docs/read%20me.txt" {
+		t.Errorf("unexpected url %q %v", url, err)
+	}
+	if _, err = router.URL("user.show", nil); err == nil {
+		t.Error("expected error for missing param")
+	}
+	if _, err = router.URL("missing", nil); err == nil {
+		t.Error("expected error for unknown route")
+	}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Route is returned by the registration methods to configure the registered
+// route further.
+type Route struct {
+	router *Router
+	routes []*route
+}
+
+// Name names the route so its path can be built with Router.URL.
+func (rt *Route) Name(name string) *Route {
+	if rt.router.names == nil {
+		rt.router.names = make(map[string]*route)
+	}
+	if exist, ok := rt.router.names[name]; ok && exist.path != rt.routes[0].path {
+		panic("route name '" + name + "' is already used by path '" + exist.path + "'")
+	}
+	for _, route := range rt.routes {
+		route.name = name
+		rt.router.names[name] = route
+	}
+	return rt
+}
+
+// MaxBodySize limits the request body of the route to n bytes, larger
+// bodies are answered with 413.
+func (rt *Route) MaxBodySize(n int64) *Route {
+	for _, route := range rt.routes {
+		route.maxBodySize = n
+	}
+	return rt
+}
+
+// DisallowUnknownFields rejects JSON bodies with fields the in struct does not
+// have.
+func (rt *Route) DisallowUnknownFields() *Route {
+	for _, route := range rt.routes {
+		route.disallowUnknownFields = true
+	}
+	return rt
+}
+
+type RouteInfo struct {
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Name       string   `json:"name,omitempty"`
+	Handlers   []string `json:"handlers"`
+	MiddleWare []string `json:"middleware"`
+}
+
+// Routes returns the registered routes in registration order.
+func (r *Router) Routes() []RouteInfo {
+	root := r.rootRouter()
+	routes := make([]RouteInfo, 0, len(root.routes))
+	for _, route := range root.routes {
+		own := len(route.own)
+		middleWare := route.handlers[:len(route.handlers)-own]
+		routes = append(routes, RouteInfo{
+			Method:     route.method,
+			Path:       route.path,
+			Name:       route.name,
+			Handlers:   handlerNames(route.own),
+			MiddleWare: handlerNames(middleWare),
+		})
+	}
+	return routes
+}
+
+func handlerNames(handlers HandlerChain) []string {
+	names := make([]string, len(handlers))
+	for i, handler := range handlers {
+		names[i] = nameOfHandler(handler)
+	}
+	return names
+}
+
+func nameOfHandler(handler Handler) string {
+	if typed, ok := handler.(typedHandler); ok {
+		return typed.handlerName()
+	}
+	value := reflect.ValueOf(handler)
+	if value.Kind() == reflect.Func {
+		if fn := runtime.FuncForPC(value.Pointer()); fn != nil {
+			return fn.Name()
+		}
+	}
+	return fmt.Sprintf("%T", handler)
+}
+
+// URL builds the path of a named route. Params fill the path wildcards, the
+// remaining params are added as query string.
+func (r *Router) URL(name string, params map[string]string) (string, error) {
+	route, exist := r.rootRouter().names[name]
+	if !exist {
+		return "", errors.New("http: no route named '" + name + "'")
+	}
+	used := make(map[string]bool)
+	segments := strings.Split(route.path, "/")
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		key := segment[1:]
+		value, exist := params[key]
+		if !exist {
+			return "", fmt.Errorf("http: missing param '%s' for route '%s'", key, name)
+		}
+		used[key] = true
+		if segment[0] == ':' {
+			segments[i] = url.PathEscape(value)
+			continue
+		}
+		parts := strings.Split(value, "/")
+		for j := range parts {
+			parts[j] = url.PathEscape(parts[j])
+		}
+		segments[i] = strings.Join(parts, "/")
+	}
+	path := strings.Join(segments, "/")
+
+	query := url.Values{}
+	for key, value := range params {
+		if !used[key] {
+			query.Set(key, value)
+		}
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// SetDebug turns on debug mode, Run prints the route table at startup.
+func (r *Router) SetDebug(debug bool) *Router {
+	r.rootRouter().debug = debug
+	return r
+}
+
+func (r *Router) PrintRoutes() {
+	routes := r.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	for _, route := range routes {
+		name := route.Name
+		if name == "" {
+			name = "-"
+		}
+		Debug(
+			"[route]",
+			fmt.Sprintf("%-7s", route.Method),
+			route.Path,
+			name,
+			strings.Join(route.Handlers, ","),
+			"("+strings.Join(route.MiddleWare, ",")+")",
+		)
+	}
+}
+
+// Run listens on addr and serves the router.
+func (r *Router) Run(addr string) error {
+	root := r.rootRouter()
+	if root.debug {
+		root.PrintRoutes()
+	}
+	return http.ListenAndServe(addr, root)
+}
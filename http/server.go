@@ -5,6 +5,7 @@ import (
 	"strings"
 	"fmt"
 	"net/http"
+	"context"
 )
 
 func RecoveryHandler(c *Context) {
@@ -12,11 +13,14 @@ func RecoveryHandler(c *Context) {
 		if err := recover(); err != nil {
 			log.Log("ERROR", "[panic]", err)
 			c.DieWithHttpStatus(http.StatusInternalServerError)
+			c.Abort()
 		}
 	}()
 	c.Next()
 }
 
+// LogHandler logs every request with the status and size of its response,
+// once it is sent.
 func LogHandler(c *Context) {
 	start := time.Now()
 	path := c.Request.URL.Path
@@ -33,34 +37,37 @@ func LogHandler(c *Context) {
 		ip = c.Request.RemoteAddr
 	}
 
-	c.Next()
-
-	traceId := c.Request.Header.Get("Kelp-Traceid")
-	uuid := c.Request.Header.Get("uuid")
-	end := time.Now()
-	latency := end.Sub(start)
-	method := c.Request.Method
-	resp := string(c.responseData)
-	if len(resp) > 500 {
-		resp = fmt.Sprintf("response is too large (with %d bytes, head is %s)", len(resp), resp[0:100]+"...")
-	}
-	req := string(c.Body())
-	if raw != "" {
-		path = path + "?" + raw
-	}
+	c.OnAfterWrite(func(c *Context) {
+		traceId := c.Request.Header.Get("Kelp-Traceid")
+		uuid := c.Request.Header.Get("uuid")
+		end := time.Now()
+		latency := end.Sub(start)
+		method := c.Request.Method
+		resp := string(c.responseData)
+		if len(resp) > 500 {
+			resp = fmt.Sprintf("response is too large (with %d bytes, head is %s)", len(resp), resp[0:100]+"...")
+		}
+		req := string(c.Body())
+		if raw != "" {
+			path = path + "?" + raw
+		}
 
-	log.Log(
-		"REQ",
-		ip, // remote ip
-		end.Format("2006/01/02 15:04:05"),
-		latency.Nanoseconds()/int64(time.Millisecond),
-		str(method),
-		str(path),
-		str(traceId), // trace id
-		str(uuid),    // uuid
-		`"""`+str(req)+`"""`,
-		`"""`+str(resp)+`"""`,
-	)
+		log.Log(
+			"REQ",
+			ip, // remote ip
+			end.Format("2006/01/02 15:04:05"),
+			latency.Nanoseconds()/int64(time.Millisecond),
+			str(method),
+			str(path),
+			c.ResponseWriter.Status(),
+			c.ResponseWriter.Size(),
+			str(traceId), // trace id
+			str(uuid),    // uuid
+			`"""`+str(req)+`"""`,
+			`"""`+str(resp)+`"""`,
+		)
+	})
+	c.Next()
 }
 
 func str(v string) string {
@@ -69,3 +76,27 @@ func str(v string) string {
 	}
 	return v
 }
+
+// DeadlineHandler sets a deadline of d on the request context. Handlers see it
+// through c.Done() and c.Err() and should stop when it expires, the request
+// is then answered with 503 whatever they wrote. The deadline is cooperative:
+// unlike http.TimeoutHandler it does not answer while a handler ignoring it
+// still runs, the client waits until the handler returns. Other errors
+// wrapping context.DeadlineExceeded, e.g. of a downstream call with its own
+// timeout, are answered with 504 by Fail.
+//
+//	router.GET("/report", DeadlineHandler(5*time.Second), buildReport)
+func DeadlineHandler(d time.Duration) Handler {
+	return func(c *Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+		if ctx.Err() == context.DeadlineExceeded && !c.ResponseWriter.Written() {
+			c.writeError(http.StatusServiceUnavailable, &ErrorResponse{
+				Status:  http.StatusServiceUnavailable,
+				Message: "request timed out",
+			})
+		}
+	}
+}
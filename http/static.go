@@ -0,0 +1,234 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"html"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticConfig configures Router.Static and Router.StaticFS.
+type StaticConfig struct {
+	// Listing lists the files of directories without index.html.
+	Listing bool
+	// SPA answers index.html of the root for paths without a file extension
+	// that do not exist, so client side routes of single page apps load.
+	SPA bool
+	// MaxAge is sent as Cache-Control max-age, 0 sends none.
+	MaxAge time.Duration
+}
+
+// Static serves the files of dir under prefix.
+//
+//	router.Static("/assets", "./public")
+//	router.Static("/", "./dist", StaticConfig{SPA: true})
+func (r *Router) Static(prefix, dir string, config ...StaticConfig) *Route {
+	return r.StaticFS(prefix, os.DirFS(dir), config...)
+}
+
+// StaticFS serves the files of fsys under prefix, e.g. of an embed.FS:
+//
+//	//go:embed dist
+//	var dist embed.FS
+//
+//	sub, _ := fs.Sub(dist, "dist")
+//	router.StaticFS("/", sub, StaticConfig{SPA: true})
+func (r *Router) StaticFS(prefix string, fsys fs.FS, config ...StaticConfig) *Route {
+	server := &fileServer{fsys: fsys}
+	if len(config) > 0 {
+		server.config = config[0]
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	handler := func(c *Context) {
+		server.serve(c, c.Param("filepath"))
+	}
+	route := r.GET(prefix+"/*filepath", handler)
+	if prefix != "" {
+		root := r.GET(prefix, handler)
+		route.routes = append(root.routes, route.routes...)
+	}
+	return route
+}
+
+// File answers the file at file of the local file system.
+func (this *Context) File(file string) {
+	dir, name := filepath.Split(filepath.Clean(file))
+	if dir == "" {
+		dir = "."
+	}
+	this.FileFS(os.DirFS(dir), name)
+}
+
+// FileFS answers the file name of fsys.
+func (this *Context) FileFS(fsys fs.FS, name string) {
+	server := &fileServer{fsys: fsys}
+	server.serveFile(this, name)
+}
+
+// Attachment answers the file at file as a download named name.
+func (this *Context) Attachment(file, name string) {
+	this.SetHeader("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	this.File(file)
+}
+
+type fileServer struct {
+	fsys   fs.FS
+	config StaticConfig
+	// etags caches the content hash of files without modification time,
+	// e.g. of an embed.FS
+	etags sync.Map
+}
+
+func (s *fileServer) serve(c *Context, name string) {
+	name = strings.Trim(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		if s.config.SPA && path.Ext(name) == "" && errors.Is(err, fs.ErrNotExist) {
+			c.SetHeader("Cache-Control", "no-cache")
+			s.serveFile(c, "index.html")
+			return
+		}
+		s.failed(c, err)
+		return
+	}
+	if !info.IsDir() {
+		s.serveFile(c, name)
+		return
+	}
+
+	// relative links of directories need the trailing slash
+	if !strings.HasSuffix(c.Request.URL.Path, "/") {
+		location := c.Request.URL.Path + "/"
+		if c.Request.URL.RawQuery != "" {
+			location += "?" + c.Request.URL.RawQuery
+		}
+		c.Redirect(http.StatusMovedPermanently, location)
+		return
+	}
+	index := path.Join(name, "index.html")
+	if _, err := fs.Stat(s.fsys, index); err == nil {
+		s.serveFile(c, index)
+		return
+	}
+	if !s.config.Listing {
+		c.DieWithHttpStatus(http.StatusForbidden)
+		return
+	}
+	s.list(c, name)
+}
+
+func (s *fileServer) serveFile(c *Context, name string) {
+	file, err := s.fsys.Open(name)
+	if err != nil {
+		s.failed(c, err)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		s.failed(c, err)
+		return
+	}
+	if info.IsDir() {
+		c.DieWithHttpStatus(http.StatusForbidden)
+		return
+	}
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			s.failed(c, err)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+	header := c.ResponseWriter.Header()
+	if header.Get("ETag") == "" {
+		etag, err := s.etag(name, info, content)
+		if err != nil {
+			s.failed(c, err)
+			return
+		}
+		header.Set("ETag", etag)
+	}
+	if s.config.MaxAge > 0 && header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(s.config.MaxAge.Seconds())))
+	}
+	c.hasResponse = true
+	// ServeContent answers Range, If-Modified-Since and If-None-Match
+	http.ServeContent(c.ResponseWriter, c.Request, info.Name(), info.ModTime(), content)
+}
+
+// etag derives the ETag from size and modification time, or from the content
+// when the file has no modification time.
+func (s *fileServer) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	hash := fnv.New64a()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := fmt.Sprintf(`"%x-%x"`, hash.Sum64(), info.Size())
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+func (s *fileServer) list(c *Context, name string) {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		s.failed(c, err)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	var b bytes.Buffer
+	b.WriteString("<!doctype html>\n<meta name=\"viewport\" content=\"width=device-width\">\n<pre>\n")
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() {
+			entryName += "/"
+		}
+		link := url.URL{Path: entryName}
+		fmt.Fprintf(&b, "<a href=\"%s\">%s</a>\n", html.EscapeString(link.String()), html.EscapeString(entryName))
+	}
+	b.WriteString("</pre>\n")
+	c.Data(http.StatusOK, "text/html;charset=UTF-8", b.Bytes())
+}
+
+func (s *fileServer) failed(c *Context, err error) {
+	switch {
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrInvalid):
+		c.DieWithHttpStatus(http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		c.DieWithHttpStatus(http.StatusForbidden)
+	default:
+		Error("[static]", err)
+		c.DieWithHttpStatus(http.StatusInternalServerError)
+	}
+}
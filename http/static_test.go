@@ -0,0 +1,138 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func serveStatic(router *Router, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, nil)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRouter_Static(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "docs"), 0755)
+	os.MkdirAll(filepath.Join(dir, "empty"), 0755)
+	os.WriteFile(filepath.Join(dir, "app.js"), []byte("console.log(1)"), 0644)
+	os.WriteFile(filepath.Join(dir, "docs", "index.html"), []byte("<h1>docs</h1>"), 0644)
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	os.Chtimes(filepath.Join(dir, "app.js"), modTime, modTime)
+
+	router := New()
+	router.Static("/assets", dir, StaticConfig{MaxAge: time.Hour})
+	router.Static("/browse", dir, StaticConfig{Listing: true})
+
+	w := serveStatic(router, http.MethodGet, "/assets/app.js", nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || w.Body.String() != "console.log(1)" || etag == "" ||
+		!strings.HasPrefix(w.Header().Get("Content-Type"), "text/javascript") ||
+		w.Header().Get("Cache-Control") != "public, max-age=3600" {
+		t.Fatalf("unexpected response %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		header map[string]string
+		status int
+		body   string
+	}{
+		{http.MethodGet, "/assets/app.js", map[string]string{"Range": "bytes=0-6"}, http.StatusPartialContent, "console"},
+		{http.MethodGet, "/assets/app.js", map[string]string{"If-None-Match": etag}, http.StatusNotModified, ""},
+		{http.MethodGet, "/assets/app.js", map[string]string{"If-Modified-Since": modTime.Format(http.TimeFormat)}, http.StatusNotModified, ""},
+		{http.MethodHead, "/assets/app.js", nil, http.StatusOK, ""},
+		{http.MethodGet, "/assets/docs/", nil, http.StatusOK, "<h1>docs</h1>"},
+		{http.MethodGet, "/assets/docs", nil, http.StatusMovedPermanently, ""},
+		{http.MethodGet, "/assets", nil, http.StatusMovedPermanently, ""},
+		{http.MethodGet, "/assets/empty/", nil, http.StatusForbidden, ""},
+		{http.MethodGet, "/assets/missing.js", nil, http.StatusNotFound, ""},
+		{http.MethodGet, "/assets/../static_test.go", nil, http.StatusNotFound, ""},
+		{http.MethodGet, "/browse/", nil, http.StatusOK, "<a href=\"app.js\">app.js</a>\n<a href=\"docs/\">docs/</a>\n"},
+	}
+	for _, test := range tests {
+		w := serveStatic(router, test.method, test.path, test.header)
+		if w.Code != test.status {
+			t.Errorf("%s %s %v: expected %d, got %d", test.method, test.path, test.header, test.status, w.Code)
+		}
+		if test.body != "" && !strings.Contains(w.Body.String(), test.body) {
+			t.Errorf("%s %s: unexpected body %q", test.method, test.path, w.Body.String())
+		}
+	}
+	if w := serveStatic(router, http.MethodGet, "/assets/docs", nil); w.Header().Get("Location") != "/assets/docs/" {
+		t.Errorf("unexpected redirect %s", w.Header().Get("Location"))
+	}
+}
+
+func TestRouter_StaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("<div id=app></div>")},
+		"assets/app.js": {Data: []byte("app()")},
+	}
+	router := New()
+	router.GET("/api/users", func(c *Context) {
+		c.Json("users")
+	})
+	router.StaticFS("/", fsys, StaticConfig{SPA: true})
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", http.StatusOK, "<div id=app></div>"},
+		{"/users/42", http.StatusOK, "<div id=app></div>"},
+		{"/assets/app.js", http.StatusOK, "app()"},
+		{"/assets/missing.js", http.StatusNotFound, ""},
+		{"/api/users", http.StatusOK, `{"data":"users","status":0}`},
+	}
+	for _, test := range tests {
+		w := serveStatic(router, http.MethodGet, test.path, nil)
+		if w.Code != test.status || (test.body != "" && w.Body.String() != test.body) {
+			t.Errorf("%s: unexpected %d %q", test.path, w.Code, w.Body.String())
+		}
+	}
+
+	w := serveStatic(router, http.MethodGet, "/assets/app.js", nil)
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag for files without modification time")
+	}
+	if w := serveStatic(router, http.MethodGet, "/assets/app.js", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", w.Code)
+	}
+}
+
+func TestContext_Attachment(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "report-1.csv")
+	os.WriteFile(file, []byte("id\n1\n"), 0644)
+
+	router := New()
+	router.GET("/report", func(c *Context) {
+		c.Attachment(file, "report 2024.csv")
+	})
+	router.GET("/file", func(c *Context) {
+		c.File(filepath.Join(dir, "missing.csv"))
+	})
+
+	w := serveStatic(router, http.MethodGet, "/report", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "id\n1\n" ||
+		w.Header().Get("Content-Disposition") != `attachment; filename="report 2024.csv"` {
+		t.Errorf("unexpected attachment %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	if w := serveStatic(router, http.MethodGet, "/file", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
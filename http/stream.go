@@ -0,0 +1,162 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stream calls step until it returns false, flushing what it wrote after every
+// call. It returns true when the client went away before.
+//
+//	c.Stream(func(w io.Writer) bool {
+//		row, ok := <-rows
+//		if ok {
+//			fmt.Fprintln(w, row)
+//		}
+//		return ok
+//	})
+func (this *Context) Stream(step func(w io.Writer) bool) bool {
+	this.hasResponse = true
+	done := this.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		default:
+		}
+		keepOpen := step(this.ResponseWriter)
+		this.ResponseWriter.Flush()
+		if !keepOpen {
+			return false
+		}
+	}
+}
+
+// SSEvent is one Server-Sent Event. Data is written as is when it is a string
+// or []byte, as JSON otherwise. Retry tells the client how long to wait
+// before reconnecting.
+type SSEvent struct {
+	ID    string
+	Event string
+	Data  interface{}
+	Retry time.Duration
+}
+
+// SSE sends one event of a Server-Sent Events stream and flushes it.
+func (this *Context) SSE(event string, data interface{}) error {
+	return this.SendEvent(SSEvent{Event: event, Data: data})
+}
+
+// SendEvent sends e, the first event also writes the event-stream headers.
+func (this *Context) SendEvent(e SSEvent) error {
+	var b bytes.Buffer
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	if e.ID != "" {
+		b.WriteString("id: " + sseLine(e.ID) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + sseLine(e.Event) + "\n")
+	}
+	if e.Data != nil {
+		var data []byte
+		switch value := e.Data.(type) {
+		case string:
+			data = []byte(value)
+		case []byte:
+			data = value
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			data = encoded
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			b.WriteString("data: " + strings.TrimSuffix(line, "\r") + "\n")
+		}
+	}
+	b.WriteString("\n")
+	return this.writeEvent(b.Bytes())
+}
+
+// EventStream sends the events of events until it is closed or the client
+// went away, in which case it returns true. Every heartbeat without events it
+// sends a comment to keep proxies from closing the connection, 0 disables it.
+//
+//	router.GET("/progress", func(c *Context) {
+//		events := make(chan SSEvent)
+//		go job.Report(c.LastEventID(), events)
+//		c.EventStream(15*time.Second, events)
+//	})
+func (this *Context) EventStream(heartbeat time.Duration, events <-chan SSEvent) bool {
+	var tick <-chan time.Time
+	if heartbeat > 0 {
+		ticker := time.NewTicker(heartbeat)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	this.prepareEventStream()
+	this.ResponseWriter.Flush()
+	done := this.Request.Context().Done()
+	for {
+		select {
+		case <-done:
+			return true
+		case e, ok := <-events:
+			if !ok {
+				return false
+			}
+			if err := this.SendEvent(e); err != nil {
+				Error("[sse]", err)
+				return true
+			}
+		case <-tick:
+			if err := this.writeEvent([]byte(": heartbeat\n\n")); err != nil {
+				return true
+			}
+		}
+	}
+}
+
+// LastEventID is the id of the last event a reconnecting client received.
+func (this *Context) LastEventID() string {
+	return this.Request.Header.Get("Last-Event-ID")
+}
+
+func (this *Context) prepareEventStream() {
+	this.hasResponse = true
+	if this.ResponseWriter.Written() {
+		return
+	}
+	header := this.ResponseWriter.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+}
+
+func (this *Context) writeEvent(data []byte) error {
+	select {
+	case <-this.Request.Context().Done():
+		return this.Request.Context().Err()
+	default:
+	}
+	this.prepareEventStream()
+	if _, err := this.ResponseWriter.Write(data); err != nil {
+		return fmt.Errorf("http: write event: %w", err)
+	}
+	this.ResponseWriter.Flush()
+	return nil
+}
+
+// sseLine keeps an id or event name on one line.
+func sseLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
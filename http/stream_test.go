@@ -0,0 +1,149 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContext_Stream(t *testing.T) {
+	logger := &recordLogger{}
+	SetLogger(logger)
+	defer SetLogger(&cmdLogger{})
+
+	router := New()
+	router.Use(LogHandler)
+	router.GET("/export", func(c *Context) {
+		c.SetHeader("Content-Type", "text/csv")
+		i := 0
+		c.Stream(func(w io.Writer) bool {
+			i++
+			fmt.Fprintf(w, "row %d\n", i)
+			return i < 3
+		})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/export", nil))
+	if w.Body.String() != "row 1\nrow 2\nrow 3\n" || !w.Flushed {
+		t.Errorf("unexpected stream %q flushed %v", w.Body.String(), w.Flushed)
+	}
+	if w.Header().Get("Content-Type") != "text/csv" {
+		t.Errorf("unexpected content type %s", w.Header().Get("Content-Type"))
+	}
+	if len(logger.lines) != 1 || fmt.Sprint(logger.lines[0][6]) != "200" || fmt.Sprint(logger.lines[0][7]) != "18" {
+		t.Errorf("expected status and size of the stream logged, got %v", logger.lines)
+	}
+}
+
+func TestContext_StreamStatus(t *testing.T) {
+	router := New()
+	router.POST("/export", func(c *Context) {
+		c.Status(http.StatusCreated).Stream(func(w io.Writer) bool {
+			io.WriteString(w, "row")
+			return false
+		})
+	})
+	router.POST("/events", func(c *Context) {
+		c.Status(http.StatusAccepted)
+		c.SSE("queued", 1)
+	})
+	router.POST("/write", func(c *Context) {
+		c.OnBeforeWrite(func(c *Context) {
+			c.SetHeader("X-Status", fmt.Sprint(c.httpStatus))
+		})
+		c.Status(http.StatusPartialContent)
+		c.ResponseWriter.Write([]byte("part"))
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/export", http.StatusCreated},
+		{"/events", http.StatusAccepted},
+		{"/write", http.StatusPartialContent},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, nil))
+		if w.Code != test.status {
+			t.Errorf("%s: expected %d, got %d %s", test.path, test.status, w.Code, w.Body.String())
+		}
+		if test.path == "/write" && w.Header().Get("X-Status") != "206" {
+			t.Errorf("expected the hook to see 206, got %s", w.Header().Get("X-Status"))
+		}
+	}
+}
+
+func TestContext_StreamDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	c := newContext(req, httptest.NewRecorder(), nil)
+	calls := 0
+	gone := c.Stream(func(w io.Writer) bool {
+		calls++
+		if calls == 2 {
+			cancel()
+		}
+		return true
+	})
+	if !gone || calls != 2 {
+		t.Errorf("expected stream to stop after disconnect, gone %v calls %d", gone, calls)
+	}
+}
+
+func TestContext_SSE(t *testing.T) {
+	router := New()
+	router.GET("/events", func(c *Context) {
+		c.SendEvent(SSEvent{Retry: 3 * time.Second})
+		c.SSE("message", "line 1\nline 2")
+		c.SendEvent(SSEvent{ID: c.LastEventID() + "1", Event: "progress", Data: map[string]int{"done": 50}})
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "4")
+	router.ServeHTTP(w, req)
+	expected := "retry: 3000\n\n" +
+		"event: message\ndata: line 1\ndata: line 2\n\n" +
+		"id: 41\nevent: progress\ndata: {\"done\":50}\n\n"
+	if w.Body.String() != expected {
+		t.Errorf("unexpected events %q", w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "text/event-stream" || w.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers %v", w.Header())
+	}
+}
+
+func TestContext_EventStream(t *testing.T) {
+	router := New()
+	router.GET("/events", func(c *Context) {
+		events := make(chan SSEvent)
+		go func() {
+			defer close(events)
+			time.Sleep(30 * time.Millisecond)
+			events <- SSEvent{Event: "done", Data: "ok"}
+		}()
+		c.EventStream(10*time.Millisecond, events)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/events", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, ": heartbeat\n\n") || !strings.HasSuffix(body, "event: done\ndata: ok\n\n") {
+		t.Errorf("unexpected events %q", body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newContext(httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx), httptest.NewRecorder(), nil)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if !c.EventStream(0, make(chan SSEvent)) {
+		t.Error("expected event stream to stop when the client went away")
+	}
+}
@@ -0,0 +1,205 @@
+package http
+
+import (
+	"strings"
+)
+
+type Param struct {
+	Key   string
+	Value string
+}
+
+type Params []Param
+
+// ByName returns the value of the first param with the given name.
+func (ps Params) ByName(name string) string {
+	for i := range ps {
+		if ps[i].Key == name {
+			return ps[i].Value
+		}
+	}
+	return ""
+}
+
+type nodeType uint8
+
+const (
+	static nodeType = iota
+	param
+	catchAll
+)
+
+// node is a radix tree node. Static nodes hold a path prefix, param and
+// catchAll nodes hold the wildcard name. Lookup prefers static children,
+// then the param child, then the catch-all child.
+type node struct {
+	path      string
+	nType     nodeType
+	indices   []byte
+	children  []*node
+	paramNode *node
+	catchNode *node
+
+	route *route
+}
+
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// staticEnd returns the length of the static part at the head of path.
+func staticEnd(path string) int {
+	if i := strings.IndexAny(path, ":*"); i >= 0 {
+		return i
+	}
+	return len(path)
+}
+
+func (n *node) addRoute(r *route) {
+	if r.path == "" || r.path[0] != '/' {
+		panic("path must begin with '/' in path '" + r.path + "'")
+	}
+	n.insert(r.path, r)
+}
+
+// insert adds path below the static node n.
+func (n *node) insert(path string, r *route) {
+	i := longestCommonPrefix(n.path, path[:staticEnd(path)])
+	if i < len(n.path) {
+		n.split(i)
+	}
+	n.insertRest(path[i:], r)
+}
+
+func (n *node) split(i int) {
+	child := &node{
+		path:      n.path[i:],
+		nType:     static,
+		indices:   n.indices,
+		children:  n.children,
+		paramNode: n.paramNode,
+		catchNode: n.catchNode,
+		route:     n.route,
+	}
+	n.path = n.path[:i]
+	n.indices = []byte{child.path[0]}
+	n.children = []*node{child}
+	n.paramNode = nil
+	n.catchNode = nil
+	n.route = nil
+}
+
+// insertRest adds path below n once n itself has been fully matched.
+func (n *node) insertRest(path string, r *route) {
+	if path == "" {
+		if n.route != nil {
+			panic("handlers are already registered for path '" + r.path + "'")
+		}
+		n.route = r
+		return
+	}
+
+	if path[0] == ':' || path[0] == '*' {
+		n.insertWildcard(path, r)
+		return
+	}
+
+	for i, c := range n.indices {
+		if c == path[0] {
+			n.children[i].insert(path, r)
+			return
+		}
+	}
+	child := &node{path: path[:staticEnd(path)], nType: static}
+	n.indices = append(n.indices, path[0])
+	n.children = append(n.children, child)
+	child.insert(path, r)
+}
+
+func (n *node) insertWildcard(path string, r *route) {
+	if !strings.HasSuffix(n.path, "/") {
+		panic("wildcard must start a path segment in path '" + r.path + "'")
+	}
+	end := strings.IndexByte(path, '/')
+	if end < 0 {
+		end = len(path)
+	}
+	name := path[1:end]
+	if name == "" || strings.ContainsAny(name, ":*") {
+		panic("invalid wildcard '" + path[:end] + "' in path '" + r.path + "'")
+	}
+
+	if path[0] == '*' {
+		if end != len(path) {
+			panic("catch-all must be the last segment in path '" + r.path + "'")
+		}
+		if n.catchNode != nil {
+			panic("catch-all '" + path + "' conflicts with existing '" + n.catchNode.route.path + "'")
+		}
+		n.catchNode = &node{path: name, nType: catchAll, route: r}
+		return
+	}
+
+	if n.paramNode == nil {
+		n.paramNode = &node{path: name, nType: param}
+	} else if n.paramNode.path != name {
+		panic("param ':" + name + "' conflicts with existing ':" + n.paramNode.path + "' in path '" + r.path + "'")
+	}
+	n.paramNode.insertRest(path[end:], r)
+}
+
+// getValue returns the node holding the route for path, appending wildcard
+// values to params. It returns nil when nothing matches.
+func (n *node) getValue(path string, params *Params) *node {
+	if len(path) < len(n.path) || path[:len(n.path)] != n.path {
+		return nil
+	}
+	return n.lookupRest(path[len(n.path):], params)
+}
+
+func (n *node) lookupRest(path string, params *Params) *node {
+	if path == "" {
+		if n.route != nil {
+			return n
+		}
+	} else {
+		c := path[0]
+		for i, idx := range n.indices {
+			if idx == c {
+				if value := n.children[i].getValue(path, params); value != nil {
+					return value
+				}
+				break
+			}
+		}
+
+		if n.paramNode != nil {
+			end := strings.IndexByte(path, '/')
+			if end < 0 {
+				end = len(path)
+			}
+			if end > 0 {
+				mark := len(*params)
+				*params = append(*params, Param{Key: n.paramNode.path, Value: path[:end]})
+				if value := n.paramNode.lookupRest(path[end:], params); value != nil {
+					return value
+				}
+				*params = (*params)[:mark]
+			}
+		}
+	}
+
+	if n.catchNode != nil {
+		*params = append(*params, Param{Key: n.catchNode.path, Value: path})
+		return n.catchNode
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"testing"
+)
+
+func TestTree_GetValue(t *testing.T) {
+	tree := &node{}
+	routes := []string{
+		"/",
+		"/users",
+		"/users/new",
+		"/users/:id",
+		"/users/:id/posts/:post",
+		"/static/*filepath",
+		"/static/favicon.ico",
+	}
+	for _, path := range routes {
+		tree.addRoute(&route{path: path})
+	}
+
+	tests := []struct {
+		path   string
+		route  string
+		params Params
+	}{
+		{"/", "/", nil},
+		{"/users", "/users", nil},
+		{"/users/new", "/users/new", nil},
+		{"/users/42", "/users/:id", Params{{"id", "42"}}},
+		{"/users/newer", "/users/:id", Params{{"id", "newer"}}},
+		{"/users/42/posts/7", "/users/:id/posts/:post", Params{{"id", "42"}, {"post", "7"}}},
+		{"/static/favicon.ico", "/static/favicon.ico", nil},
+		{"/static/js/app.js", "/static/*filepath", Params{{"filepath", "js/app.js"}}},
+		{"/static/", "/static/*filepath", Params{{"filepath", ""}}},
+		{"/users/", "", nil},
+		{"/users/42/posts", "", nil},
+		{"/nothing", "", nil},
+	}
+	for _, test := range tests {
+		var params Params
+		value := tree.getValue(test.path, &params)
+		if test.route == "" {
+			if value != nil {
+				t.Errorf("%s: expected no match, got %s", test.path, value.route.path)
+			}
+			continue
+		}
+		if value == nil {
+			t.Errorf("%s: expected %s, got no match", test.path, test.route)
+			continue
+		}
+		if value.route.path != test.route {
+			t.Errorf("%s: expected %s, got %s", test.path, test.route, value.route.path)
+		}
+		if len(params) != len(test.params) {
+			t.Errorf("%s: expected params %v, got %v", test.path, test.params, params)
+			continue
+		}
+		for i := range params {
+			if params[i] != test.params[i] {
+				t.Errorf("%s: expected params %v, got %v", test.path, test.params, params)
+			}
+		}
+	}
+}
+
+func TestTree_Conflicts(t *testing.T) {
+	conflicts := [][]string{
+		{"/users/:id", "/users/:name"},
+		{"/users/:id", "/users/:id"},
+		{"/files/*path", "/files/*other"},
+		{"/files/*path/more"},
+		{"/user_:name"},
+		{"no-slash"},
+	}
+	for _, routes := range conflicts {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %v", routes)
+				}
+			}()
+			tree := &node{}
+			for _, path := range routes {
+				tree.addRoute(&route{path: path})
+			}
+		}()
+	}
+}
+
+func TestTree_StaticLookupAllocs(t *testing.T) {
+	tree := &node{}
+	tree.addRoute(&route{path: "/users/new"})
+	tree.addRoute(&route{path: "/users/:id"})
+	allocs := testing.AllocsPerRun(100, func() {
+		var params Params
+		tree.getValue("/users/new", &params)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations for static lookup, got %v", allocs)
+	}
+}
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+// typedHandler is implemented by the handlers Handle returns, they are
+// invoked directly instead of through reflection.
+type typedHandler interface {
+	compile() (invoker, error)
+	io() (in, out reflect.Type)
+	handlerName() string
+}
+
+type typedHandlerFunc[In, Out any] struct {
+	fn func(ctx *Context, in *In) (*Out, error)
+}
+
+// Handle adapts a typed func to a Handler. The in struct is bound and
+// validated like the in struct of func(in, out) handlers, the returned out is
+// answered with Json and the error with Fail:
+//
+//	router.POST("/hello", Handle(func(c *Context, in *In) (*Out, error) {
+//		return &Out{Text: "Hello " + in.Name}, nil
+//	}))
+func Handle[In, Out any](fn func(ctx *Context, in *In) (*Out, error)) Handler {
+	return &typedHandlerFunc[In, Out]{fn: fn}
+}
+
+func (h *typedHandlerFunc[In, Out]) compile() (invoker, error) {
+	inType, _ := h.io()
+	if inType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("handler in param must be a pointer to struct, got *%v", inType)
+	}
+	plan, err := validPlanOf(inType)
+	if err != nil {
+		return nil, err
+	}
+	fn := h.fn
+	return func(c *Context) {
+		in := new(In)
+		if err := c.Bind(in); err != nil {
+			c.bindFailed(err)
+			c.Abort()
+			return
+		}
+		if !plan.empty() {
+			var errs ValidationErrors
+			plan.validate(reflect.ValueOf(in).Elem(), "", &errs)
+			if len(errs) > 0 {
+				c.validationFailed(errs)
+				c.Abort()
+				return
+			}
+		}
+		out, err := fn(c, in)
+		if !isNilError(err) {
+			c.AbortWithError(err)
+			return
+		}
+		if out != nil {
+			c.Render(c.httpStatus, out)
+			return
+		}
+		c.emptyResponse()
+	}, nil
+}
+
+func (h *typedHandlerFunc[In, Out]) io() (in, out reflect.Type) {
+	return reflect.TypeOf((*In)(nil)).Elem(), reflect.TypeOf((*Out)(nil)).Elem()
+}
+
+func (h *typedHandlerFunc[In, Out]) handlerName() string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(h.fn).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return fmt.Sprintf("%T", h.fn)
+}
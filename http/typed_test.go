@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type typedIn struct {
+	ID   int64  `path:"id"`
+	Name string `json:"name" valid:"required"`
+}
+
+type typedOut struct {
+	Text string `json:"text"`
+}
+
+func typedHello(c *Context, in *typedIn) (*typedOut, error) {
+	if in.Name == "nobody" {
+		return nil, &ErrorResponse{Status: 1001, Message: "nobody"}
+	}
+	return &typedOut{Text: "Hello " + in.Name}, nil
+}
+
+func TestHandle(t *testing.T) {
+	router := New()
+	router.POST("/users/:id", Handle(typedHello))
+	router.POST("/legacy", HelloPost)
+
+	tests := []struct {
+		path   string
+		body   string
+		status int
+		resp   string
+	}{
+		{"/users/1", `{"name":"Lywane"}`, http.StatusOK, `{"data":{"text":"Hello Lywane"},"status":0}`},
+		{"/users/1", `{}`, http.StatusBadRequest, `{"status":400,"message":"validation failed","data":[{"field":"name","rule":"required","message":"is required"}]}`},
+		{"/users/x", `{"name":"Lywane"}`, http.StatusBadRequest, ""},
+		{"/users/1", `{"name":"nobody"}`, http.StatusBadRequest, `{"status":1001,"message":"nobody"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, test.path, strings.NewReader(test.body)))
+		if w.Code != test.status {
+			t.Errorf("%s %s: expected %d, got %d %s", test.path, test.body, test.status, w.Code, w.Body.String())
+		}
+		if test.resp != "" && w.Body.String() != test.resp {
+			t.Errorf("%s %s: unexpected body %s", test.path, test.body, w.Body.String())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/legacy", strings.NewReader(`{"name":"Lywane"}`)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected reflective handler to keep working, got %d", w.Code)
+	}
+
+	routes := router.Routes()
+	if !strings.HasSuffix(routes[0].Handlers[0], ".typedHello") {
+		t.Errorf("unexpected handler name %v", routes[0].Handlers)
+	}
+	doc := router.OpenAPI(OpenAPIInfo{})
+	if doc.Components.Schemas["typedOut"] == nil {
+		t.Error("expected typed out struct in the OpenAPI document")
+	}
+}
@@ -0,0 +1,263 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+var (
+	// ErrFileTooLarge is wrapped by the errors of files over MaxFileSize.
+	ErrFileTooLarge = errors.New("file too large")
+	// ErrFileType is wrapped by the errors of files whose sniffed type is not
+	// in AllowedTypes.
+	ErrFileType = errors.New("file type not allowed")
+)
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	fileHeaderSliceType = reflect.TypeOf([]*multipart.FileHeader(nil))
+)
+
+// UploadConfig limits multipart uploads.
+type UploadConfig struct {
+	// MaxMemory is how many bytes of files are kept in memory, the rest is
+	// written to temporary files. 32MB by default.
+	MaxMemory int64
+	// MaxFileSize limits every file, 0 means no limit. Files are checked
+	// after the body is read, so it does not bound the disk space taken
+	// meanwhile, MaxTotalSize does.
+	MaxFileSize int64
+	// MaxTotalSize limits the whole multipart body and with it the disk
+	// space an upload takes, 0 means the max body size of the route.
+	MaxTotalSize int64
+	// AllowedTypes are the MIME types files may have, sniffed from their
+	// content. "image/*" allows all images, no types allow all files.
+	AllowedTypes []string
+}
+
+// SetUploadConfig sets the limits of multipart uploads of every route.
+// Route.Upload overrides it.
+func (r *Router) SetUploadConfig(config UploadConfig) *Router {
+	r.rootRouter().upload = &config
+	return r
+}
+
+// Upload sets the limits of multipart uploads of the route.
+func (rt *Route) Upload(config UploadConfig) *Route {
+	for _, route := range rt.routes {
+		route.upload = &config
+	}
+	return rt
+}
+
+func (this *Context) uploadConfig() UploadConfig {
+	var config UploadConfig
+	switch {
+	case this.route != nil && this.route.upload != nil:
+		config = *this.route.upload
+	case this.router != nil && this.router.upload != nil:
+		config = *this.router.upload
+	}
+	if config.MaxMemory <= 0 {
+		config.MaxMemory = defaultMaxMemory
+	}
+	return config
+}
+
+// MultipartForm parses a multipart/form-data body once and checks its files
+// against the UploadConfig. The body is streamed, so Body is empty after.
+// The temporary files are removed when the request is done.
+func (this *Context) MultipartForm() (*multipart.Form, error) {
+	if this.Request.MultipartForm != nil {
+		return this.Request.MultipartForm, nil
+	}
+	if this.multipartErr != nil {
+		return nil, this.multipartErr
+	}
+	form, err := this.parseMultipart()
+	if err != nil {
+		this.multipartErr = err
+		return nil, err
+	}
+	this.Request.MultipartForm = form
+	this.multipartForm = form
+	return form, nil
+}
+
+func (this *Context) parseMultipart() (*multipart.Form, error) {
+	contentType, params, err := mime.ParseMediaType(this.Request.Header.Get("Content-Type"))
+	if err != nil || contentType != "multipart/form-data" {
+		return nil, http.ErrNotMultipart
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, http.ErrMissingBoundary
+	}
+	config := this.uploadConfig()
+
+	var body io.Reader
+	if this.hasReadBody {
+		if this.bodyErr != nil {
+			return nil, this.bodyErr
+		}
+		body = bytes.NewReader(this.body)
+		if config.MaxTotalSize > 0 && int64(len(this.body)) > config.MaxTotalSize {
+			return nil, &http.MaxBytesError{Limit: config.MaxTotalSize}
+		}
+	} else {
+		this.hasReadBody = true
+		limit := this.maxBodySize()
+		if config.MaxTotalSize > 0 {
+			limit = config.MaxTotalSize
+		}
+		body = this.Request.Body
+		if limit > 0 {
+			if this.Request.ContentLength > limit {
+				this.bodyErr = &http.MaxBytesError{Limit: limit}
+				return nil, this.bodyErr
+			}
+			body = http.MaxBytesReader(this.ResponseWriter, this.Request.Body, limit)
+		}
+	}
+
+	form, err := multipart.NewReader(body, boundary).ReadForm(config.MaxMemory)
+	if err != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(err, &maxBytes) {
+			return nil, maxBytes
+		}
+		return nil, &BindError{Source: "body", Err: err}
+	}
+	if err := checkFiles(form, config); err != nil {
+		form.RemoveAll()
+		return nil, err
+	}
+	return form, nil
+}
+
+// checkFiles checks the size and sniffed type of every file of form.
+func checkFiles(form *multipart.Form, config UploadConfig) error {
+	for name, files := range form.File {
+		for _, file := range files {
+			if config.MaxFileSize > 0 && file.Size > config.MaxFileSize {
+				return &BindError{Source: "file", Name: name, Value: file.Filename,
+					Err: fmt.Errorf("%w, %d bytes at most", ErrFileTooLarge, config.MaxFileSize)}
+			}
+			if len(config.AllowedTypes) == 0 {
+				continue
+			}
+			fileType, err := sniffFile(file)
+			if err != nil {
+				return &BindError{Source: "file", Name: name, Value: file.Filename, Err: err}
+			}
+			if !typeAllowed(fileType, config.AllowedTypes) {
+				return &BindError{Source: "file", Name: name, Value: file.Filename,
+					Err: fmt.Errorf("%w: %s", ErrFileType, fileType)}
+			}
+		}
+	}
+	return nil
+}
+
+// sniffFile detects the MIME type of file from its first 512 bytes.
+func sniffFile(file *multipart.FileHeader) (string, error) {
+	f, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	fileType, _, _ := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	return fileType, nil
+}
+
+func typeAllowed(fileType string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if pattern == fileType || pattern == "*/*" ||
+			(strings.HasSuffix(pattern, "/*") && strings.HasPrefix(fileType, pattern[:len(pattern)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormValue returns the first value of a form field of the body, then of the
+// query string.
+func (this *Context) FormValue(name string) string {
+	if values := this.postForm()[name]; len(values) > 0 {
+		return values[0]
+	}
+	return this.GetUrlParam(name)
+}
+
+// postForm returns the fields of form encoded and multipart bodies.
+func (this *Context) postForm() url.Values {
+	if this.Request.PostForm != nil {
+		return this.Request.PostForm
+	}
+	contentType, _, _ := mime.ParseMediaType(this.Request.Header.Get("Content-Type"))
+	form := url.Values{}
+	switch contentType {
+	case "multipart/form-data":
+		if multipartForm, err := this.MultipartForm(); err == nil {
+			form = url.Values(multipartForm.Value)
+		}
+	case "application/x-www-form-urlencoded":
+		if body, err := this.ReadBody(); err == nil {
+			if parsed, err := url.ParseQuery(string(body)); err == nil {
+				form = parsed
+			}
+		}
+	}
+	this.Request.PostForm = form
+	return form
+}
+
+// FormFile returns the first file uploaded as name. It returns
+// http.ErrMissingFile when there is none.
+func (this *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	form, err := this.MultipartForm()
+	if err != nil {
+		return nil, err
+	}
+	if files := form.File[name]; len(files) > 0 {
+		return files[0], nil
+	}
+	return nil, http.ErrMissingFile
+}
+
+// SaveUploadedFile writes file to dst, creating its directory. Do not build
+// dst from file.Filename or other client input without cleaning it.
+func (this *Context) SaveUploadedFile(file *multipart.FileHeader, dst string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,147 @@
+package http
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type uploadFile struct {
+	field, name string
+	data        []byte
+}
+
+func multipartRequest(path string, fields map[string]string, files ...uploadFile) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	for _, file := range files {
+		part, _ := writer.CreateFormFile(file.field, file.name)
+		part.Write(file.data)
+	}
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+type uploadIn struct {
+	Title   string                  `form:"title" valid:"required"`
+	Avatar  *multipart.FileHeader   `form:"avatar"`
+	Photos  []*multipart.FileHeader `form:"photos"`
+	Private bool                    `form:"private"`
+}
+
+type uploadOut struct {
+	Title  string   `json:"title"`
+	Avatar string   `json:"avatar"`
+	Photos []string `json:"photos"`
+}
+
+func TestContext_FormFile(t *testing.T) {
+	dir := t.TempDir()
+	router := New()
+	router.POST("/upload", func(c *Context) error {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			return err
+		}
+		if err := c.SaveUploadedFile(file, filepath.Join(dir, "avatars", file.Filename)); err != nil {
+			return err
+		}
+		c.Json(c.FormValue("title") + " " + c.FormValue("page"))
+		return nil
+	})
+
+	req := multipartRequest("/upload?page=2", map[string]string{"title": "me"}, uploadFile{"avatar", "me.png", pngHeader})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != `{"data":"me 2","status":0}` {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if saved, err := os.ReadFile(filepath.Join(dir, "avatars", "me.png")); err != nil || !bytes.Equal(saved, pngHeader) {
+		t.Errorf("unexpected saved file %q %v", saved, err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, multipartRequest("/upload", map[string]string{"title": "me"}))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "no such file") {
+		t.Errorf("expected missing file error, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestContext_BindFiles(t *testing.T) {
+	router := New()
+	router.SetUploadConfig(UploadConfig{MaxMemory: 16, MaxFileSize: 1024, AllowedTypes: []string{"image/*"}})
+	router.POST("/album", func(in *uploadIn, out *uploadOut) {
+		out.Title = in.Title
+		if in.Avatar != nil {
+			out.Avatar = in.Avatar.Filename
+		}
+		for _, photo := range in.Photos {
+			out.Photos = append(out.Photos, photo.Filename)
+		}
+	})
+	router.POST("/small", func(in *uploadIn, out *uploadOut) {}).Upload(UploadConfig{MaxTotalSize: 256})
+
+	big := append(append([]byte{}, pngHeader...), bytes.Repeat([]byte{0}, 2048)...)
+	tests := []struct {
+		path   string
+		files  []uploadFile
+		status int
+		body   string
+	}{
+		{"/album", []uploadFile{{"avatar", "a.png", pngHeader}, {"photos", "1.png", pngHeader}, {"photos", "2.png", pngHeader}},
+			http.StatusOK, `{"data":{"title":"trip","avatar":"a.png","photos":["1.png","2.png"]},"status":0}`},
+		{"/album", []uploadFile{{"photos", "big.png", big}}, http.StatusRequestEntityTooLarge,
+			`{"status":413,"message":"file 'big.png' of 'photos': file too large, 1024 bytes at most","data":{"source":"file","field":"photos","value":"big.png"}}`},
+		{"/album", []uploadFile{{"avatar", "a.png", []byte("#!/bin/sh\nrm -rf /")}}, http.StatusUnsupportedMediaType,
+			`{"status":415,"message":"file 'a.png' of 'avatar': file type not allowed: text/plain","data":{"source":"file","field":"avatar","value":"a.png"}}`},
+		{"/small", []uploadFile{{"avatar", "big.png", big}}, http.StatusRequestEntityTooLarge,
+			`{"status":413,"message":"request body is larger than 256 bytes"}`},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, multipartRequest(test.path, map[string]string{"title": "trip"}, test.files...))
+		if w.Code != test.status || w.Body.String() != test.body {
+			t.Errorf("%s %v: unexpected %d %s", test.path, test.files[0].name, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestContext_MultipartCleanup(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	router := New()
+	router.SetUploadConfig(UploadConfig{MaxMemory: 1})
+	router.POST("/upload", DeadlineHandler(time.Second), func(c *Context) error {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			return err
+		}
+		if temps, _ := filepath.Glob(filepath.Join(tmp, "multipart-*")); len(temps) == 0 {
+			t.Error("expected the upload to be written to a temporary file")
+		}
+		c.Json(file.Filename)
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, multipartRequest("/upload", nil, uploadFile{"avatar", "me.png", pngHeader}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+	if temps, _ := filepath.Glob(filepath.Join(tmp, "multipart-*")); len(temps) != 0 {
+		t.Errorf("temporary files left behind: %v", temps)
+	}
+}
@@ -0,0 +1,412 @@
+package http
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ValidatorFunc reports whether value satisfies a rule, param is the text
+// after "=" in the tag, e.g. "1" for min=1.
+type ValidatorFunc func(value reflect.Value, param string) bool
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every field failing its valid tag.
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Field + " " + err.Message
+	}
+	return "http: validation failed: " + strings.Join(messages, "; ")
+}
+
+var emailPattern = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+var (
+	validatorsMu sync.RWMutex
+	validators   = map[string]ValidatorFunc{
+		"min":      sizeRule(func(cmp int) bool { return cmp >= 0 }),
+		"max":      sizeRule(func(cmp int) bool { return cmp <= 0 }),
+		"len":      sizeRule(func(cmp int) bool { return cmp == 0 }),
+		"email":    validEmail,
+		"oneof":    validOneOf,
+		"date":     func(v reflect.Value, param string) bool { return validTime(v, param, DefaultDateFM) },
+		"datetime": func(v reflect.Value, param string) bool { return validTime(v, param, DefaultDateTimeFM) },
+	}
+)
+
+// RegisterValidator makes a rule usable in valid tags. Register validators
+// before the routes using them.
+func RegisterValidator(name string, fn ValidatorFunc) {
+	validatorsMu.Lock()
+	defer validatorsMu.Unlock()
+	validators[name] = fn
+}
+
+func validatorOf(name string) ValidatorFunc {
+	validatorsMu.RLock()
+	defer validatorsMu.RUnlock()
+	return validators[name]
+}
+
+type validRule struct {
+	name  string
+	param string
+	fn    ValidatorFunc
+}
+
+type validField struct {
+	index     []int
+	name      string
+	required  bool
+	omitempty bool
+	rules     []validRule
+}
+
+type validPlan struct {
+	fields []validField
+	// nested holds the fields holding structs which have rules themselves
+	nested []validField
+}
+
+var validPlans sync.Map
+
+func (plan *validPlan) empty() bool {
+	return len(plan.fields) == 0 && len(plan.nested) == 0
+}
+
+// Validate checks the valid tags of v, a struct or pointer to struct, and
+// returns ValidationErrors when any field fails. Rules check zero values
+// too, so min=18 on an int rejects 0, unless the field has omitempty. Nil
+// pointers are only checked by required.
+//
+//	type In struct {
+//		Name     string `json:"name" valid:"required,min=1,max=64"`
+//		Email    string `json:"email" valid:"omitempty,email"`
+//		Kind     string `json:"kind" valid:"oneof=a b"`
+//		Birthday string `json:"birthday" valid:"omitempty,date"`
+//	}
+func Validate(v interface{}) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	plan, err := validPlanOf(value.Type())
+	if err != nil {
+		return err
+	}
+	var errs ValidationErrors
+	plan.validate(value, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validPlanOf(t reflect.Type) (*validPlan, error) {
+	return buildValidPlan(t, make(map[reflect.Type]bool))
+}
+
+// buildValidPlan returns nil for types already being built further up, seen
+// guards recursive types.
+func buildValidPlan(t reflect.Type, seen map[reflect.Type]bool) (*validPlan, error) {
+	if plan, ok := validPlans.Load(t); ok {
+		return plan.(*validPlan), nil
+	}
+	if seen[t] {
+		return nil, nil
+	}
+	seen[t] = true
+	plan := &validPlan{}
+	if err := collectRules(plan, t, nil, seen); err != nil {
+		return nil, err
+	}
+	actual, _ := validPlans.LoadOrStore(t, plan)
+	return actual.(*validPlan), nil
+}
+
+func collectRules(plan *validPlan, t reflect.Type, index []int, seen map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		fieldIndex := append(append([]int{}, index...), i)
+		elem := field.Type
+		for elem.Kind() == reflect.Ptr || elem.Kind() == reflect.Slice || elem.Kind() == reflect.Array {
+			elem = elem.Elem()
+		}
+		if field.Anonymous && elem.Kind() == reflect.Struct && field.Type.Kind() != reflect.Slice {
+			if err := collectRules(plan, elem, fieldIndex, seen); err != nil {
+				return err
+			}
+			continue
+		}
+
+		vf := validField{index: fieldIndex, name: fieldName(field)}
+		if tag := field.Tag.Get("valid"); tag != "" && tag != "-" {
+			for _, rule := range strings.Split(tag, ",") {
+				rule = strings.TrimSpace(rule)
+				if rule == "" {
+					continue
+				}
+				if rule == "required" {
+					vf.required = true
+					continue
+				}
+				if rule == "omitempty" {
+					vf.omitempty = true
+					continue
+				}
+				name, param := rule, ""
+				if i := strings.IndexByte(rule, '='); i >= 0 {
+					name, param = rule[:i], rule[i+1:]
+				}
+				fn := validatorOf(name)
+				if fn == nil {
+					return fmt.Errorf("unknown validator '%s' on field %s.%s", name, t, field.Name)
+				}
+				if err := checkSizeRule(name, param, field.Type); err != nil {
+					return fmt.Errorf("validator '%s' on field %s.%s: %v", rule, t, field.Name, err)
+				}
+				vf.rules = append(vf.rules, validRule{name: name, param: param, fn: fn})
+			}
+			plan.fields = append(plan.fields, vf)
+		}
+
+		if elem.Kind() == reflect.Struct && elem != timeType {
+			nested, err := buildValidPlan(elem, seen)
+			if err != nil {
+				return err
+			}
+			// a nil plan is a recursive type, validate it to be safe
+			if nested == nil || !nested.empty() {
+				plan.nested = append(plan.nested, vf)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldName is the name a client knows the field by.
+func fieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "path", "query", "header", "form"} {
+		name := strings.Split(field.Tag.Get(tag), ",")[0]
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+func (plan *validPlan) validate(value reflect.Value, prefix string, errs *ValidationErrors) {
+	for _, field := range plan.fields {
+		fv, ok := fieldValue(value, field.index)
+		name := prefix + field.name
+		if !ok || fv.IsZero() {
+			if field.required {
+				*errs = append(*errs, &FieldError{Field: name, Rule: "required", Message: "is required"})
+				continue
+			}
+			// nil pointers were not sent, zero values were unless omitempty
+			if !ok || field.omitempty || fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+				continue
+			}
+		}
+		for fv.Kind() == reflect.Ptr || fv.Kind() == reflect.Interface {
+			fv = fv.Elem()
+		}
+		for _, rule := range field.rules {
+			if !rule.fn(fv, rule.param) {
+				*errs = append(*errs, &FieldError{Field: name, Rule: rule.name, Message: ruleMessage(rule)})
+			}
+		}
+	}
+	for _, field := range plan.nested {
+		fv, ok := fieldValue(value, field.index)
+		if !ok {
+			continue
+		}
+		validateNested(fv, prefix+field.name, errs)
+	}
+}
+
+func validateNested(value reflect.Value, name string, errs *ValidationErrors) {
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			validateNested(value.Index(i), name+"["+strconv.Itoa(i)+"]", errs)
+		}
+	case reflect.Struct:
+		if plan, err := validPlanOf(value.Type()); err == nil {
+			plan.validate(value, name+".", errs)
+		}
+	}
+}
+
+// fieldValue walks index, ok is false when an embedded pointer is nil.
+func fieldValue(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+func ruleMessage(rule validRule) string {
+	switch rule.name {
+	case "min":
+		return "must be at least " + rule.param
+	case "max":
+		return "must be at most " + rule.param
+	case "len":
+		return "must have size " + rule.param
+	case "email":
+		return "must be an email address"
+	case "oneof":
+		return "must be one of [" + rule.param + "]"
+	case "date":
+		return "must be a date like " + timeLayout(rule.param, DefaultDateFM)
+	case "datetime":
+		return "must be a time like " + timeLayout(rule.param, DefaultDateTimeFM)
+	}
+	if rule.param != "" {
+		return "failed on " + rule.name + "=" + rule.param
+	}
+	return "failed on " + rule.name
+}
+
+// sizeRule passes values whose comparison with param is accepted, values which
+// cannot be compared fail.
+func sizeRule(accept func(cmp int) bool) ValidatorFunc {
+	return func(v reflect.Value, param string) bool {
+		cmp, ok := compareSize(v, param)
+		return ok && accept(cmp)
+	}
+}
+
+// checkSizeRule rejects min, max and len rules whose param is not a number
+// or whose field has no size.
+func checkSizeRule(name, param string, t reflect.Type) error {
+	if name != "min" && name != "max" && name != "len" {
+		return nil
+	}
+	if _, err := strconv.ParseFloat(param, 64); err != nil {
+		return fmt.Errorf("param '%s' is not a number", param)
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Interface,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return nil
+	}
+	return fmt.Errorf("%v has no size", t)
+}
+
+// compareSize compares the length of strings, slices and maps or the value of
+// numbers with param. ok is false when they cannot be compared.
+func compareSize(v reflect.Value, param string) (cmp int, ok bool) {
+	var size, limit float64
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return 0, false
+	}
+	switch v.Kind() {
+	case reflect.String:
+		size = float64(utf8.RuneCountInString(v.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		size = float64(v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = v.Float()
+	default:
+		return 0, false
+	}
+	switch {
+	case size < limit:
+		return -1, true
+	case size > limit:
+		return 1, true
+	}
+	return 0, true
+}
+
+func validEmail(v reflect.Value, param string) bool {
+	return v.Kind() == reflect.String && len(v.String()) <= 254 && emailPattern.MatchString(v.String())
+}
+
+func validOneOf(v reflect.Value, param string) bool {
+	var s string
+	switch v.Kind() {
+	case reflect.String:
+		s = v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(v.Uint(), 10)
+	default:
+		return false
+	}
+	for _, option := range strings.Fields(param) {
+		if s == option {
+			return true
+		}
+	}
+	return false
+}
+
+func timeLayout(param, layout string) string {
+	if param != "" {
+		return param
+	}
+	return layout
+}
+
+// validTime accepts time.Time values and strings in the layout given as param,
+// or in layout when there is none.
+func validTime(v reflect.Value, param, layout string) bool {
+	if v.Type() == timeType {
+		return true
+	}
+	if v.Kind() != reflect.String {
+		return false
+	}
+	_, err := time.Parse(timeLayout(param, layout), v.String())
+	return err == nil
+}
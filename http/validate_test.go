@@ -0,0 +1,158 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type validAddress struct {
+	City string `json:"city" valid:"required"`
+}
+
+type validIn struct {
+	Name      string          `json:"name" valid:"required,min=2,max=8"`
+	Email     string          `json:"email" valid:"omitempty,email"`
+	Kind      string          `json:"kind" valid:"omitempty,oneof=a b"`
+	Birthday  string          `json:"birthday" valid:"omitempty,date"`
+	Login     string          `json:"login" valid:"omitempty,datetime"`
+	Age       int             `json:"age" valid:"omitempty,min=1,max=150"`
+	Code      string          `json:"code" valid:"omitempty,upper"`
+	Page      int             `query:"page" valid:"required"`
+	Address   *validAddress   `json:"address"`
+	Addresses []*validAddress `json:"addresses"`
+}
+
+func init() {
+	RegisterValidator("upper", func(v reflect.Value, param string) bool {
+		return v.Kind() == reflect.String && strings.ToUpper(v.String()) == v.String()
+	})
+}
+
+func TestValidate(t *testing.T) {
+	in := &validIn{
+		Name:      "L",
+		Email:     "not-an-email",
+		Kind:      "c",
+		Birthday:  "1994/06/25",
+		Login:     "1994-06-25 08:00:00",
+		Age:       200,
+		Code:      "abc",
+		Address:   &validAddress{},
+		Addresses: []*validAddress{{City: "Beijing"}, {}},
+	}
+	errs, ok := Validate(in).(ValidationErrors)
+	if !ok {
+		t.Fatal("expected ValidationErrors")
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Field+":"+err.Rule)
+	}
+	expected := "name:min,email:email,kind:oneof,birthday:date,age:max,code:upper,page:required,address.city:required,addresses[1].city:required"
+	if strings.Join(got, ",") != expected {
+		t.Errorf("unexpected errors %s", strings.Join(got, ","))
+	}
+
+	valid := &validIn{Name: "Lywane", Kind: "a", Birthday: "1994-06-25", Page: 1}
+	if err := Validate(valid); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestValidate_Handler(t *testing.T) {
+	router := New()
+	router.POST("/valid", func(in *validIn, out *Out) *ErrorResponse {
+		out.Text = in.Name
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/valid?page=1", strings.NewReader(`{"name":"L"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", w.Code)
+	}
+	res := struct {
+		Status  int           `json:"status"`
+		Message string        `json:"message"`
+		Data    []*FieldError `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != http.StatusBadRequest || len(res.Data) != 1 || res.Data[0].Field != "name" || res.Data[0].Rule != "min" {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/valid?page=1", strings.NewReader(`{"name":"Lywane"}`)))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestValidate_UnknownRule(t *testing.T) {
+	defer func() {
+		if err := recover(); err == nil || !strings.Contains(err.(string), "unknown validator 'nope'") {
+			t.Errorf("expected unknown validator panic, got %v", err)
+		}
+	}()
+	New().POST("/unknown", func(in *struct {
+		Name string `valid:"nope"`
+	}, out *Out) {
+	})
+}
+
+func TestValidate_InvalidSizeRule(t *testing.T) {
+	tests := []struct {
+		in     interface{}
+		expect string
+	}{
+		{&struct {
+			Name string `valid:"max=abc"`
+		}{}, "param 'abc' is not a number"},
+		{&struct {
+			Active bool `valid:"max=1"`
+		}{}, "bool has no size"},
+		{&struct {
+			At *time.Time `valid:"min=1"`
+		}{}, "time.Time has no size"},
+	}
+	for _, test := range tests {
+		err := Validate(test.in)
+		if err == nil || !strings.Contains(err.Error(), test.expect) {
+			t.Errorf("%T: expected %q, got %v", test.in, test.expect, err)
+		}
+	}
+}
+
+func TestValidate_ZeroValues(t *testing.T) {
+	type in struct {
+		Age    int      `valid:"min=18"`
+		Kind   string   `valid:"oneof=a b"`
+		Tags   []string `valid:"min=1"`
+		Note   string   `valid:"omitempty,min=3"`
+		Count  *int     `valid:"min=1"`
+		Needed *int     `valid:"min=1"`
+		Name   string   `valid:"required,omitempty,min=2"`
+	}
+	zero := 0
+	errs, ok := Validate(&in{Needed: &zero}).(ValidationErrors)
+	if !ok {
+		t.Fatal("expected ValidationErrors")
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Field+":"+err.Rule)
+	}
+	if expected := "Age:min,Kind:oneof,Tags:min,Needed:min,Name:required"; strings.Join(got, ",") != expected {
+		t.Errorf("unexpected errors %s", strings.Join(got, ","))
+	}
+	if err := Validate(&in{Age: 18, Kind: "a", Tags: []string{"x"}, Name: "Ly"}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
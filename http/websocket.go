@@ -0,0 +1,575 @@
+package http
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types of WSConn.
+const (
+	WSText   = 1
+	WSBinary = 2
+	wsClose  = 8
+	wsPing   = 9
+	wsPong   = 10
+)
+
+// Close codes of RFC 6455 section 7.4.1.
+const (
+	WSCloseNormal          = 1000
+	WSCloseGoingAway       = 1001
+	WSCloseProtocolError   = 1002
+	WSCloseUnsupportedData = 1003
+	WSCloseNoStatus        = 1005
+	WSCloseAbnormal        = 1006
+	WSCloseInvalidPayload  = 1007
+	WSClosePolicyViolation = 1008
+	WSCloseTooBig          = 1009
+	WSCloseInternalError   = 1011
+)
+
+const (
+	wsGUID             = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	defaultWSReadLimit = 1 << 20
+)
+
+// WSCloseError is returned by the reads of a WSConn once the connection is
+// closed, Code is the close code the peer sent or WSCloseAbnormal.
+type WSCloseError struct {
+	Code int
+	Text string
+}
+
+func (e *WSCloseError) Error() string {
+	if e.Text != "" {
+		return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+	}
+	return fmt.Sprintf("websocket: close %d", e.Code)
+}
+
+// WSConfig configures the WebSocket endpoints of Router.WS.
+type WSConfig struct {
+	// Subprotocols the server speaks by preference, the first one the client
+	// offers too is chosen.
+	Subprotocols []string
+	// CheckOrigin rejects the upgrade when it returns false. By default only
+	// requests without Origin or from the same host are accepted.
+	CheckOrigin func(r *http.Request) bool
+	// PingInterval sends a ping that often and closes the connection when
+	// nothing arrives for two intervals. 0 disables keepalive.
+	PingInterval time.Duration
+	// WriteTimeout bounds every write, 0 means no limit.
+	WriteTimeout time.Duration
+}
+
+// WSHandler handles an upgraded WebSocket connection. The connection is closed
+// when it returns.
+type WSHandler func(c *Context, conn *WSConn)
+
+type wsEndpoint struct {
+	fn     WSHandler
+	config WSConfig
+}
+
+// WS registers a WebSocket endpoint for GET path. The upgrade runs through the
+// middleware of the router like every other route, so auth and recovery
+// apply. Messages are limited to the MaxBodySize of the route or router, 1MB
+// by default:
+//
+//	router.WS("/feed", func(c *Context, conn *WSConn) {
+//		for {
+//			kind, data, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			conn.WriteMessage(kind, data)
+//		}
+//	}).MaxBodySize(64 << 10)
+func (r *Router) WS(path string, handler WSHandler, config ...WSConfig) *Route {
+	h := &wsEndpoint{fn: handler}
+	if len(config) > 0 {
+		h.config = config[0]
+	}
+	return r.GET(path, h)
+}
+
+func (h *wsEndpoint) compile() (invoker, error) {
+	if h.fn == nil {
+		return nil, errors.New("websocket handler must not be nil")
+	}
+	return func(c *Context) {
+		conn, err := c.upgrade(h.config)
+		if err != nil {
+			return
+		}
+		defer func() {
+			if err := recover(); err != nil {
+				conn.Close(WSCloseInternalError, "")
+				panic(err)
+			}
+			conn.Close(WSCloseNormal, "")
+		}()
+		h.fn(c, conn)
+	}, nil
+}
+
+func (h *wsEndpoint) io() (in, out reflect.Type) {
+	return nil, nil
+}
+
+func (h *wsEndpoint) handlerName() string {
+	if fn := runtime.FuncForPC(reflect.ValueOf(h.fn).Pointer()); fn != nil {
+		return fn.Name()
+	}
+	return fmt.Sprintf("%T", h.fn)
+}
+
+// upgrade performs the handshake, failures are answered with 400, 403 or
+// 426 and returned.
+func (this *Context) upgrade(config WSConfig) (*WSConn, error) {
+	req := this.Request
+	fail := func(status int, message string) error {
+		this.writeError(status, &ErrorResponse{Status: status, Message: message})
+		return errors.New("websocket: " + message)
+	}
+	if !headerHasToken(req.Header, "Connection", "upgrade") || !headerHasToken(req.Header, "Upgrade", "websocket") {
+		this.SetHeader("Upgrade", "websocket")
+		return nil, fail(http.StatusUpgradeRequired, "websocket upgrade required")
+	}
+	if req.Header.Get("Sec-WebSocket-Version") != "13" {
+		this.SetHeader("Sec-WebSocket-Version", "13")
+		return nil, fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+	checkOrigin := config.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = sameOrigin
+	}
+	if !checkOrigin(req) {
+		return nil, fail(http.StatusForbidden, "origin not allowed")
+	}
+	hijacker, ok := this.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, fail(http.StatusInternalServerError, "connection does not support hijacking")
+	}
+
+	subprotocol := ""
+	offered := headerTokens(req.Header, "Sec-WebSocket-Protocol")
+	for _, protocol := range config.Subprotocols {
+		if contains(offered, protocol) {
+			subprotocol = protocol
+			break
+		}
+	}
+
+	this.hasResponse = true
+	this.runBeforeWrite(http.StatusSwitchingProtocols)
+	header := this.ResponseWriter.Header().Clone()
+	netConn, rw, err := hijacker.Hijack()
+	if err != nil {
+		Error("[websocket]", err)
+		return nil, err
+	}
+	header.Del("Content-Type")
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", wsAccept(key))
+	if subprotocol != "" {
+		header.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(rw)
+	rw.WriteString("\r\n")
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	readLimit := this.maxBodySize()
+	if readLimit <= 0 {
+		readLimit = defaultWSReadLimit
+	}
+	conn := &WSConn{
+		conn:         netConn,
+		reader:       rw.Reader,
+		readLimit:    readLimit,
+		subprotocol:  subprotocol,
+		writeTimeout: config.WriteTimeout,
+		pingInterval: config.PingInterval,
+		done:         make(chan struct{}),
+	}
+	netConn.SetDeadline(time.Time{})
+	if conn.pingInterval > 0 {
+		conn.extendReadDeadline()
+		go conn.keepalive()
+	}
+	return conn, nil
+}
+
+func wsAccept(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+func headerTokens(header http.Header, key string) []string {
+	var tokens []string
+	for _, value := range header.Values(key) {
+		for _, token := range strings.Split(value, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func headerHasToken(header http.Header, key, token string) bool {
+	for _, t := range headerTokens(header, key) {
+		if strings.EqualFold(t, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// WSConn is an upgraded WebSocket connection. One goroutine may read and
+// another write at the same time.
+type WSConn struct {
+	conn         net.Conn
+	reader       *bufio.Reader
+	readLimit    int64
+	subprotocol  string
+	writeTimeout time.Duration
+	pingInterval time.Duration
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	closeSent bool
+	done      chan struct{}
+	// closeErr is set once a close frame arrived or reading failed
+	closeErr error
+	onPong   func(data []byte)
+}
+
+// Subprotocol is the subprotocol agreed on in the handshake.
+func (conn *WSConn) Subprotocol() string {
+	return conn.subprotocol
+}
+
+// SetReadLimit limits the size of the messages read, larger messages close
+// the connection with WSCloseTooBig.
+func (conn *WSConn) SetReadLimit(n int64) {
+	conn.readLimit = n
+}
+
+// OnPong registers fn to be called with the data of every pong.
+func (conn *WSConn) OnPong(fn func(data []byte)) {
+	conn.onPong = fn
+}
+
+// RemoteAddr is the address of the client.
+func (conn *WSConn) RemoteAddr() net.Addr {
+	return conn.conn.RemoteAddr()
+}
+
+// ReadMessage reads the next text or binary message, answering pings and
+// close frames on the way. After a close it returns *WSCloseError.
+func (conn *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	if conn.closeErr != nil {
+		return 0, nil, conn.closeErr
+	}
+	messageType, data, err = conn.readMessage()
+	if err != nil {
+		conn.closeErr = err
+	}
+	return messageType, data, err
+}
+
+func (conn *WSConn) readMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+	for {
+		fin, opcode, payload, err := conn.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := conn.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			if conn.onPong != nil {
+				conn.onPong(payload)
+			}
+			continue
+		case wsClose:
+			return 0, nil, conn.receivedClose(payload)
+		case WSText, WSBinary:
+			if messageType != 0 {
+				return 0, nil, conn.fail(WSCloseProtocolError, "expected continuation frame")
+			}
+			messageType = opcode
+		case 0:
+			if messageType == 0 {
+				return 0, nil, conn.fail(WSCloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, conn.fail(WSCloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+		}
+		if int64(len(message)+len(payload)) > conn.readLimit {
+			return 0, nil, conn.fail(WSCloseTooBig, "message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			break
+		}
+	}
+	if messageType == WSText && !utf8.Valid(message) {
+		return 0, nil, conn.fail(WSCloseInvalidPayload, "invalid utf-8 in text message")
+	}
+	return messageType, message, nil
+}
+
+func (conn *WSConn) readFrame() (fin bool, opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err = io.ReadFull(conn.reader, head[:]); err != nil {
+		return false, 0, nil, conn.readFailed(err)
+	}
+	if conn.pingInterval > 0 {
+		conn.extendReadDeadline()
+	}
+	fin = head[0]&0x80 != 0
+	opcode = int(head[0] & 0x0f)
+	if head[0]&0x70 != 0 {
+		return false, 0, nil, conn.fail(WSCloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, conn.fail(WSCloseProtocolError, "client frames must be masked")
+	}
+	length := uint64(head[1] & 0x7f)
+	isControl := opcode >= wsClose
+	if isControl && (!fin || length > 125) {
+		return false, 0, nil, conn.fail(WSCloseProtocolError, "invalid control frame")
+	}
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(conn.reader, ext[:]); err != nil {
+			return false, 0, nil, conn.readFailed(err)
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(conn.reader, ext[:]); err != nil {
+			return false, 0, nil, conn.readFailed(err)
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if !isControl && length > uint64(conn.readLimit) {
+		return false, 0, nil, conn.fail(WSCloseTooBig, "message too big")
+	}
+	var mask [4]byte
+	if _, err = io.ReadFull(conn.reader, mask[:]); err != nil {
+		return false, 0, nil, conn.readFailed(err)
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(conn.reader, payload); err != nil {
+		return false, 0, nil, conn.readFailed(err)
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// receivedClose answers a close frame of the peer with the same code.
+func (conn *WSConn) receivedClose(payload []byte) error {
+	closeErr := &WSCloseError{Code: WSCloseNoStatus}
+	switch {
+	case len(payload) == 1:
+		return conn.fail(WSCloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Text = string(payload[2:])
+		if !utf8.Valid(payload[2:]) {
+			return conn.fail(WSCloseInvalidPayload, "invalid utf-8 in close reason")
+		}
+	}
+	code := closeErr.Code
+	if code == WSCloseNoStatus {
+		code = WSCloseNormal
+	}
+	conn.sendClose(code, "")
+	conn.shutdown()
+	return closeErr
+}
+
+// fail closes the connection because of a protocol violation of the peer.
+func (conn *WSConn) fail(code int, text string) error {
+	conn.sendClose(code, text)
+	conn.shutdown()
+	return &WSCloseError{Code: code, Text: text}
+}
+
+func (conn *WSConn) readFailed(err error) error {
+	conn.shutdown()
+	if conn.closeErr != nil {
+		return conn.closeErr
+	}
+	return &WSCloseError{Code: WSCloseAbnormal, Text: err.Error()}
+}
+
+// WriteMessage sends data as one text or binary message.
+func (conn *WSConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WSText && messageType != WSBinary {
+		return fmt.Errorf("websocket: invalid message type %d", messageType)
+	}
+	return conn.writeFrame(messageType, data)
+}
+
+func (conn *WSConn) WriteText(text string) error {
+	return conn.writeFrame(WSText, []byte(text))
+}
+
+func (conn *WSConn) WriteBinary(data []byte) error {
+	return conn.writeFrame(WSBinary, data)
+}
+
+// WriteJSON sends v encoded as JSON in a text message.
+func (conn *WSConn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return conn.writeFrame(WSText, data)
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v.
+func (conn *WSConn) ReadJSON(v interface{}) error {
+	_, data, err := conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Ping sends a ping, the pong arrives through OnPong while reading.
+func (conn *WSConn) Ping(data []byte) error {
+	return conn.writeFrame(wsPing, data)
+}
+
+// Close sends a close frame with code and reason, then closes the
+// connection. Reads waiting on it return *WSCloseError.
+func (conn *WSConn) Close(code int, reason string) error {
+	err := conn.sendClose(code, reason)
+	conn.shutdown()
+	return err
+}
+
+func (conn *WSConn) sendClose(code int, reason string) error {
+	conn.writeMu.Lock()
+	sent := conn.closeSent
+	conn.closeSent = true
+	conn.writeMu.Unlock()
+	if sent {
+		return nil
+	}
+	var payload []byte
+	if code != WSCloseNoStatus {
+		payload = make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(payload, uint16(code))
+		payload = append(payload, reason...)
+		if len(payload) > 125 {
+			payload = payload[:125]
+		}
+	}
+	return conn.write(wsClose, payload)
+}
+
+func (conn *WSConn) writeFrame(opcode int, data []byte) error {
+	conn.writeMu.Lock()
+	closed := conn.closeSent
+	conn.writeMu.Unlock()
+	if closed {
+		return &WSCloseError{Code: WSCloseNormal, Text: "connection closed"}
+	}
+	return conn.write(opcode, data)
+}
+
+func (conn *WSConn) write(opcode int, data []byte) error {
+	header := make([]byte, 2, 10+len(data))
+	header[0] = 0x80 | byte(opcode)
+	switch {
+	case len(data) < 126:
+		header[1] = byte(len(data))
+	case len(data) <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(data)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(data)))
+	}
+
+	conn.writeMu.Lock()
+	defer conn.writeMu.Unlock()
+	if conn.writeTimeout > 0 {
+		conn.conn.SetWriteDeadline(time.Now().Add(conn.writeTimeout))
+	}
+	_, err := conn.conn.Write(append(header, data...))
+	return err
+}
+
+func (conn *WSConn) extendReadDeadline() {
+	conn.conn.SetReadDeadline(time.Now().Add(2 * conn.pingInterval))
+}
+
+func (conn *WSConn) keepalive() {
+	ticker := time.NewTicker(conn.pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-ticker.C:
+			if err := conn.writeFrame(wsPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (conn *WSConn) shutdown() {
+	conn.closeOnce.Do(func() {
+		close(conn.done)
+		conn.conn.Close()
+	})
+}
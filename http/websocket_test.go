@@ -0,0 +1,215 @@
+package http
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wsClient speaks just enough RFC 6455 to test the server.
+type wsClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	status int
+	header http.Header
+}
+
+func dialWS(t *testing.T, server *httptest.Server, path string, header map[string]string) *wsClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &wsClient{conn: conn, reader: reader, status: res.StatusCode, header: res.Header}
+}
+
+func (ws *wsClient) write(fin bool, opcode int, payload []byte) {
+	head := []byte{byte(opcode), 0x80}
+	if fin {
+		head[0] |= 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		head[1] |= byte(len(payload))
+	case len(payload) <= 0xffff:
+		head[1] |= 126
+		head = binary.BigEndian.AppendUint16(head, uint16(len(payload)))
+	default:
+		head[1] |= 127
+		head = binary.BigEndian.AppendUint64(head, uint64(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	masked := make([]byte, len(payload))
+	for i := range payload {
+		masked[i] = payload[i] ^ mask[i%4]
+	}
+	ws.conn.Write(append(append(head, mask...), masked...))
+}
+
+func (ws *wsClient) read() (opcode int, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(ws.reader, head[:]); err != nil {
+		return 0, nil, err
+	}
+	length := int(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(ws.reader, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(ws.reader, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(ws.reader, payload)
+	return int(head[0] & 0x0f), payload, err
+}
+
+func (ws *wsClient) expectClose(t *testing.T, code int) {
+	t.Helper()
+	opcode, payload, err := ws.read()
+	if err != nil || opcode != wsClose || len(payload) < 2 || int(binary.BigEndian.Uint16(payload)) != code {
+		t.Errorf("expected close %d, got opcode %d %v %v", code, opcode, payload, err)
+	}
+}
+
+func wsRouter(closed chan error) *Router {
+	router := New()
+	router.Use(RecoveryHandler)
+	router.Use(func(c *Context) {
+		if c.GetHeader("Authorization") == "" && c.GetUrlParam("token") == "" {
+			c.DieWithHttpStatus(http.StatusUnauthorized)
+			return
+		}
+		c.Next()
+	})
+	router.WS("/echo", func(c *Context, conn *WSConn) {
+		for {
+			kind, data, err := conn.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			if string(data) == "panic" {
+				panic("boom")
+			}
+			conn.WriteMessage(kind, data)
+		}
+	}, WSConfig{Subprotocols: []string{"chat", "json"}}).MaxBodySize(1024)
+	return router
+}
+
+func TestRouter_WS(t *testing.T) {
+	closed := make(chan error, 1)
+	server := httptest.NewServer(wsRouter(closed))
+	defer server.Close()
+
+	ws := dialWS(t, server, "/echo", map[string]string{
+		"Authorization":          "Bearer x",
+		"Sec-WebSocket-Protocol": "json, chat",
+	})
+	if ws.status != http.StatusSwitchingProtocols ||
+		ws.header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" ||
+		ws.header.Get("Sec-WebSocket-Protocol") != "chat" {
+		t.Fatalf("unexpected handshake %d %v", ws.status, ws.header)
+	}
+
+	ws.write(true, WSText, []byte("hello"))
+	if opcode, payload, _ := ws.read(); opcode != WSText || string(payload) != "hello" {
+		t.Errorf("unexpected echo %d %q", opcode, payload)
+	}
+	// a fragmented binary message with a ping in between
+	ws.write(false, WSBinary, []byte{1, 2})
+	ws.write(true, wsPing, []byte("p"))
+	ws.write(true, 0, []byte{3})
+	if opcode, payload, _ := ws.read(); opcode != wsPong || string(payload) != "p" {
+		t.Errorf("expected pong, got %d %q", opcode, payload)
+	}
+	if opcode, payload, _ := ws.read(); opcode != WSBinary || string(payload) != "\x01\x02\x03" {
+		t.Errorf("unexpected binary echo %d %v", opcode, payload)
+	}
+
+	ws.write(true, wsClose, append([]byte{0x03, 0xe9}, "bye"...))
+	ws.expectClose(t, WSCloseGoingAway)
+	var closeErr *WSCloseError
+	if err := <-closed; !errors.As(err, &closeErr) || closeErr.Code != WSCloseGoingAway || closeErr.Text != "bye" {
+		t.Errorf("unexpected close error %v", err)
+	}
+}
+
+func TestRouter_WSLimits(t *testing.T) {
+	closed := make(chan error, 1)
+	server := httptest.NewServer(wsRouter(closed))
+	defer server.Close()
+
+	ws := dialWS(t, server, "/echo?token=x", nil)
+	ws.write(true, WSText, []byte(strings.Repeat("a", 2048)))
+	ws.expectClose(t, WSCloseTooBig)
+	<-closed
+
+	ws = dialWS(t, server, "/echo?token=x", nil)
+	ws.write(true, WSText, []byte{0xff, 0xfe})
+	ws.expectClose(t, WSCloseInvalidPayload)
+	<-closed
+
+	ws = dialWS(t, server, "/echo?token=x", nil)
+	ws.write(true, WSText, []byte("panic"))
+	ws.expectClose(t, WSCloseInternalError)
+}
+
+func TestRouter_WSHandshake(t *testing.T) {
+	server := httptest.NewServer(wsRouter(make(chan error, 1)))
+	defer server.Close()
+
+	tests := []struct {
+		header map[string]string
+		status int
+	}{
+		{map[string]string{}, http.StatusUnauthorized},
+		{map[string]string{"Authorization": "x", "Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{map[string]string{"Authorization": "x", "Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+		{map[string]string{"Authorization": "x", "Origin": "http://evil.example"}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		ws := dialWS(t, server, "/echo", test.header)
+		if ws.status != test.status {
+			t.Errorf("%v: expected %d, got %d", test.header, test.status, ws.status)
+		}
+		ws.conn.Close()
+	}
+
+	res, err := http.Get(server.URL + "/echo?token=x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUpgradeRequired || res.Header.Get("Upgrade") != "websocket" {
+		t.Errorf("expected 426 for plain GET, got %d", res.StatusCode)
+	}
+}
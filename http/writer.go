@@ -0,0 +1,143 @@
+package http
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// ResponseWriter is the http.ResponseWriter of a Context. It remembers what
+// went out so middleware can tell after the handlers ran.
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	// Status is the http status written, 200 until then.
+	Status() int
+	// Size is the number of body bytes written.
+	Size() int
+	// Written reports whether the status has been written.
+	Written() bool
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	size    int
+	written bool
+	// before may change the status right before it is written
+	before func(status int) int
+}
+
+func newResponseWriter(w http.ResponseWriter, before func(status int) int) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+		status:         http.StatusOK,
+		before:         before,
+	}
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.written {
+		return
+	}
+	w.writeHeader(status)
+}
+
+// writeHeader writes status, 0 for a body written first. before then picks
+// the status set on the Context, e.g. by c.Status(201) ahead of c.Stream.
+func (w *responseWriter) writeHeader(status int) {
+	if w.before != nil {
+		status = w.before(status)
+	}
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.status = status
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	if !w.written {
+		w.writeHeader(0)
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if !w.written {
+		w.writeHeader(0)
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the writer of the server.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack hands the connection over, e.g. for WebSocket. A hijacked
+// connection counts as written with 101 Switching Protocols.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("http: response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		w.status = http.StatusSwitchingProtocols
+		w.written = true
+	}
+	return conn, rw, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.written
+}
+
+// OnBeforeWrite registers fn to run right before the status is written, after
+// the handlers ran. fn may still change the status and the headers:
+//
+//	router.Use(func(c *Context) {
+//		start := time.Now()
+//		c.OnBeforeWrite(func(c *Context) {
+//			c.SetHeader("X-Response-Time", time.Since(start).String())
+//		})
+//		c.Next()
+//	})
+func (this *Context) OnBeforeWrite(fn func(c *Context)) {
+	this.beforeWrite = append(this.beforeWrite, fn)
+}
+
+// OnAfterWrite registers fn to run once the response is sent, e.g. to log
+// its final status and size. Changes to the response are too late then.
+func (this *Context) OnAfterWrite(fn func(c *Context)) {
+	this.afterWrite = append(this.afterWrite, fn)
+}
+
+// runBeforeWrite runs the OnBeforeWrite hooks once and returns the status to
+// write, status 0 keeps the status of the Context.
+func (this *Context) runBeforeWrite(status int) int {
+	hooks := this.beforeWrite
+	this.beforeWrite = nil
+	if status != 0 {
+		this.httpStatus = status
+	}
+	for _, fn := range hooks {
+		fn(this)
+	}
+	return this.httpStatus
+}
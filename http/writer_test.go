@@ -0,0 +1,105 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type recordLogger struct {
+	lines [][]interface{}
+}
+
+func (l *recordLogger) Log(tag string, msg ...interface{}) {
+	l.lines = append(l.lines, append([]interface{}{tag}, msg...))
+}
+
+func TestContext_OnBeforeWrite(t *testing.T) {
+	router := New()
+	router.Use(func(c *Context) {
+		c.OnBeforeWrite(func(c *Context) {
+			c.SetHeader("X-Powered-By", "myweb")
+		})
+		c.OnBeforeWrite(func(c *Context) {
+			if c.GetHeader("X-Maintenance") != "" {
+				c.Status(http.StatusServiceUnavailable)
+			}
+		})
+		c.Next()
+	})
+	router.GET("/json", func(c *Context) {
+		c.Json("hi")
+	})
+	router.GET("/direct", func(c *Context) {
+		c.ResponseWriter.Write([]byte("direct"))
+	})
+
+	for _, path := range []string{"/json", "/direct"} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Maintenance", "1")
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusServiceUnavailable || w.Header().Get("X-Powered-By") != "myweb" {
+			t.Errorf("%s: hooks did not run, got %d %v", path, w.Code, w.Header())
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/direct", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "direct" {
+		t.Errorf("unexpected direct response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestResponseWriter(t *testing.T) {
+	var status, size int
+	var written bool
+	router := New()
+	router.Use(func(c *Context) {
+		c.Next()
+		c.response()
+		status, size, written = c.ResponseWriter.Status(), c.ResponseWriter.Size(), c.ResponseWriter.Written()
+	})
+	router.POST("/users", func(c *Context) {
+		c.Status(http.StatusCreated).Json("ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if status != http.StatusCreated || size != w.Body.Len() || !written {
+		t.Errorf("unexpected status %d size %d written %v", status, size, written)
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	logger := &recordLogger{}
+	SetLogger(logger)
+	defer SetLogger(&cmdLogger{})
+
+	router := New()
+	router.Use(func(c *Context) {
+		c.Next()
+		// middleware outside LogHandler may still change the response
+		if c.HandlerError() != nil {
+			c.SetHeader("X-Error", "1")
+			c.Status(http.StatusGone)
+		}
+	}, LogHandler)
+	router.GET("/missing-user", func(c *Context) {
+		c.DieWithHttpStatus(http.StatusNotFound)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing-user", nil))
+
+	if w.Code != http.StatusGone || w.Header().Get("X-Error") != "1" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+	if len(logger.lines) != 1 {
+		t.Fatalf("expected 1 log line, got %v", logger.lines)
+	}
+	line := logger.lines[0]
+	if fmt.Sprint(line[6]) != "410" || fmt.Sprint(line[7]) != fmt.Sprint(w.Body.Len()) {
+		t.Errorf("expected status and size in %v", line)
+	}
+}
@@ -0,0 +1,550 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var yamlPlain = regexp.MustCompile(`^[A-Za-z_/$][A-Za-z0-9_./${}-]*$`)
+
+var yamlReserved = map[string]bool{
+	"true": true, "false": true, "yes": true, "no": true, "on": true,
+	"off": true, "null": true, "y": true, "n": true,
+}
+
+// marshalYAML encodes v as block style YAML. v goes through encoding/json
+// first, so json tags apply and map keys are sorted.
+func marshalYAML(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	switch tree.(type) {
+	case map[string]interface{}, []interface{}:
+		if isEmptyYAML(tree) {
+			writeYAMLScalar(&b, tree)
+			b.WriteByte('\n')
+		} else {
+			writeYAML(&b, tree, 0, false)
+		}
+	default:
+		writeYAMLScalar(&b, tree)
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), nil
+}
+
+func isEmptyYAML(v interface{}) bool {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	}
+	return true
+}
+
+// writeYAML writes a non-empty collection. When inline is set the first line
+// continues the current one, as for a mapping inside a sequence item.
+func writeYAML(b *bytes.Buffer, v interface{}, indent int, inline bool) {
+	pad := strings.Repeat(" ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			if i > 0 || !inline {
+				b.WriteString(pad)
+			}
+			writeYAMLString(b, key)
+			b.WriteByte(':')
+			writeYAMLChild(b, v[key], indent, false)
+		}
+	case []interface{}:
+		for i, item := range v {
+			if i > 0 || !inline {
+				b.WriteString(pad)
+			}
+			b.WriteByte('-')
+			writeYAMLChild(b, item, indent, true)
+		}
+	}
+}
+
+func writeYAMLChild(b *bytes.Buffer, v interface{}, indent int, inSequence bool) {
+	if isEmptyYAML(v) {
+		b.WriteByte(' ')
+		writeYAMLScalar(b, v)
+		b.WriteByte('\n')
+		return
+	}
+	if _, ok := v.(map[string]interface{}); ok && inSequence {
+		b.WriteByte(' ')
+		writeYAML(b, v, indent+2, true)
+		return
+	}
+	b.WriteByte('\n')
+	writeYAML(b, v, indent+2, false)
+}
+
+func writeYAMLScalar(b *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(v))
+	case json.Number:
+		b.WriteString(v.String())
+	case string:
+		writeYAMLString(b, v)
+	case map[string]interface{}:
+		b.WriteString("{}")
+	case []interface{}:
+		b.WriteString("[]")
+	}
+}
+
+func writeYAMLString(b *bytes.Buffer, s string) {
+	if yamlPlain.MatchString(s) && !yamlReserved[strings.ToLower(s)] {
+		b.WriteString(s)
+		return
+	}
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
+
+// unmarshalYAML decodes a YAML document into v through encoding/json, so
+// json tags apply. It covers block and flow collections, plain and quoted
+// scalars, comments and | and > block scalars, not anchors or tags.
+func unmarshalYAML(data []byte, v interface{}) error {
+	parser := &yamlParser{}
+	for i, line := range strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimRight(line, " \t")
+		content := strings.TrimLeft(trimmed, " ")
+		if content == "---" || content == "..." {
+			continue
+		}
+		parser.lines = append(parser.lines, yamlLine{
+			number:  i + 1,
+			indent:  len(trimmed) - len(content),
+			content: content,
+			raw:     line,
+		})
+	}
+	parser.skipBlank()
+	if parser.pos >= len(parser.lines) {
+		return nil
+	}
+	tree, err := parser.block(parser.lines[parser.pos].indent)
+	if err != nil {
+		return err
+	}
+	parser.skipBlank()
+	if parser.pos < len(parser.lines) {
+		return parser.errorf("unexpected indentation")
+	}
+	encoded, err := json.Marshal(tree)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, v)
+}
+
+type yamlLine struct {
+	number  int
+	indent  int
+	content string
+	raw     string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+	depth int
+}
+
+func (p *yamlParser) errorf(format string, args ...interface{}) error {
+	line := 0
+	if p.pos < len(p.lines) {
+		line = p.lines[p.pos].number
+	}
+	return fmt.Errorf("yaml: line %d: %s", line, fmt.Sprintf(format, args...))
+}
+
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) {
+		content := stripYAMLComment(p.lines[p.pos].content)
+		if content != "" {
+			return
+		}
+		p.pos++
+	}
+}
+
+// block parses the collection or scalar starting at the current line.
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxDecodeDepth {
+		return nil, p.errorf("%v", errDecodeDepth)
+	}
+	p.skipBlank()
+	line := p.lines[p.pos]
+	content := stripYAMLComment(line.content)
+	switch {
+	case content == "-" || strings.HasPrefix(content, "- "):
+		return p.sequence(indent)
+	case yamlKeyEnd(content) >= 0:
+		return p.mapping(indent)
+	}
+	p.pos++
+	return p.scalar(content)
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent {
+			return list, nil
+		}
+		content := stripYAMLComment(p.lines[p.pos].content)
+		if content != "-" && !strings.HasPrefix(content, "- ") {
+			return list, nil
+		}
+		rest := strings.TrimLeft(p.lines[p.pos].content[1:], " ")
+		if stripYAMLComment(rest) == "" {
+			p.pos++
+			item, err := p.nested(indent, true)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			continue
+		}
+		// continue the item as if it started on its own line
+		offset := len(p.lines[p.pos].content) - len(rest)
+		p.lines[p.pos].indent += offset
+		p.lines[p.pos].content = rest
+		item, err := p.block(p.lines[p.pos].indent)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, item)
+	}
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent != indent {
+			return m, nil
+		}
+		content := stripYAMLComment(p.lines[p.pos].content)
+		end := yamlKeyEnd(content)
+		if end < 0 {
+			return m, nil
+		}
+		key, err := p.scalar(strings.TrimSpace(content[:end]))
+		if err != nil {
+			return nil, err
+		}
+		rest := strings.TrimSpace(content[end+1:])
+		var value interface{}
+		switch {
+		case rest == "":
+			p.pos++
+			value, err = p.nested(indent, false)
+		case rest == "|" || rest == ">" || strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+			p.pos++
+			value = p.blockScalar(indent, rest)
+		default:
+			p.pos++
+			value, err = p.scalar(rest)
+		}
+		if err != nil {
+			return nil, err
+		}
+		m[fmt.Sprint(key)] = value
+	}
+}
+
+// nested parses the value of a key or "-" whose content starts on the next
+// line. Sequences may sit at the indentation of their key.
+func (p *yamlParser) nested(indent int, inSequence bool) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	line := p.lines[p.pos]
+	content := stripYAMLComment(line.content)
+	isSequence := content == "-" || strings.HasPrefix(content, "- ")
+	if line.indent > indent || (!inSequence && line.indent == indent && isSequence) {
+		return p.block(line.indent)
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) blockScalar(indent int, header string) string {
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.content != "" && line.indent <= indent {
+			break
+		}
+		if blockIndent < 0 && line.content != "" {
+			blockIndent = line.indent
+		}
+		text := ""
+		if len(line.raw) > blockIndent && blockIndent >= 0 {
+			text = line.raw[blockIndent:]
+		}
+		lines = append(lines, text)
+		p.pos++
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var text string
+	if header[0] == '|' {
+		text = strings.Join(lines, "\n")
+	} else {
+		text = strings.Join(lines, " ")
+	}
+	if strings.HasSuffix(header, "-") {
+		return text
+	}
+	return text + "\n"
+}
+
+// scalar parses a single line value: quoted, flow collection or plain.
+func (p *yamlParser) scalar(s string) (interface{}, error) {
+	flow := &yamlFlow{s: s, depth: p.depth}
+	value, err := flow.value()
+	if err != nil {
+		return nil, p.errorf("%v", err)
+	}
+	flow.space()
+	if flow.pos != len(s) {
+		return nil, p.errorf("unexpected '%s'", s[flow.pos:])
+	}
+	return value, nil
+}
+
+// yamlKeyEnd returns the index of the colon ending a mapping key, or -1.
+func yamlKeyEnd(s string) int {
+	quote := byte(0)
+	if s == "" || s[0] == '[' || s[0] == '{' {
+		return -1
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case i == 0 && (c == '"' || c == '\''):
+			quote = c
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' '):
+			return i
+		}
+	}
+	return -1
+}
+
+func stripYAMLComment(s string) string {
+	quote := byte(0)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			if i == 0 || s[i-1] == ' ' || s[i-1] == '[' || s[i-1] == '{' || s[i-1] == ',' || s[i-1] == ':' {
+				quote = c
+			}
+		case c == '#' && (i == 0 || s[i-1] == ' '):
+			return strings.TrimRight(s[:i], " ")
+		}
+	}
+	return s
+}
+
+// yamlFlow parses flow style values: [a, b], {a: 1}, quoted and plain scalars.
+type yamlFlow struct {
+	s     string
+	pos   int
+	depth int
+}
+
+func (f *yamlFlow) space() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	f.space()
+	if f.pos >= len(f.s) {
+		return nil, nil
+	}
+	if f.s[f.pos] == '[' || f.s[f.pos] == '{' {
+		f.depth++
+		defer func() { f.depth-- }()
+		if f.depth > maxDecodeDepth {
+			return nil, errDecodeDepth
+		}
+	}
+	switch f.s[f.pos] {
+	case '[':
+		f.pos++
+		list := []interface{}{}
+		for {
+			f.space()
+			if f.pos < len(f.s) && f.s[f.pos] == ']' {
+				f.pos++
+				return list, nil
+			}
+			item, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if err := f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		m := map[string]interface{}{}
+		for {
+			f.space()
+			if f.pos < len(f.s) && f.s[f.pos] == '}' {
+				f.pos++
+				return m, nil
+			}
+			key, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			f.space()
+			if f.pos >= len(f.s) || f.s[f.pos] != ':' {
+				return nil, errors.New("expected ':' in flow mapping")
+			}
+			f.pos++
+			value, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(key)] = value
+			if err := f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"':
+		end := f.pos + 1
+		for end < len(f.s) && f.s[end] != '"' {
+			if f.s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(f.s) {
+			return nil, errors.New("unterminated double quoted string")
+		}
+		value, err := strconv.Unquote(f.s[f.pos : end+1])
+		if err != nil {
+			return nil, err
+		}
+		f.pos = end + 1
+		return value, nil
+	case '\'':
+		var b strings.Builder
+		for i := f.pos + 1; i < len(f.s); i++ {
+			if f.s[i] == '\'' {
+				if i+1 < len(f.s) && f.s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				f.pos = i + 1
+				return b.String(), nil
+			}
+			b.WriteByte(f.s[i])
+		}
+		return nil, errors.New("unterminated single quoted string")
+	}
+	return f.plain(), nil
+}
+
+func (f *yamlFlow) separator(end byte) error {
+	f.space()
+	if f.pos >= len(f.s) {
+		return fmt.Errorf("expected '%c'", end)
+	}
+	switch f.s[f.pos] {
+	case ',':
+		f.pos++
+		return nil
+	case end:
+		return nil
+	}
+	return fmt.Errorf("unexpected '%c' in flow collection", f.s[f.pos])
+}
+
+// plain reads a plain scalar. Inside flow collections it stops at , ] } and
+// at ": ".
+func (f *yamlFlow) plain() interface{} {
+	start := f.pos
+	inFlow := strings.ContainsAny(f.s[:start], "[{")
+	for f.pos < len(f.s) {
+		c := f.s[f.pos]
+		if inFlow && (c == ',' || c == ']' || c == '}') {
+			break
+		}
+		if inFlow && c == ':' && (f.pos+1 == len(f.s) || f.s[f.pos+1] == ' ') {
+			break
+		}
+		f.pos++
+	}
+	return yamlPlainValue(strings.TrimSpace(f.s[start:f.pos]))
+}
+
+func yamlPlainValue(s string) interface{} {
+	switch strings.ToLower(s) {
+	case "", "~", "null":
+		return nil
+	case "true", "yes", "on":
+		return true
+	case "false", "no", "off":
+		return false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}